@@ -2,48 +2,251 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"golang.org/x/crypto/scrypt"
 )
 
 const (
 	binMinutes    = 5
 	sampleSeconds = 30
+	syncInterval  = 15 * time.Minute
 	defaultFile   = "timetrackcli.json"
+	meetingStatus = 2 // timeline-only status for bins inside a calendar meeting
+	maxHistory    = 200
 )
 
 type Config struct {
-	DailyGoalMinutes int   `json:"daily_goal_minutes"`
-	WorkDays         []int `json:"work_days"` // 1=Monday, 7=Sunday
+	DailyGoalMinutes  int          `json:"daily_goal_minutes"`
+	WorkDays          []int        `json:"work_days"` // 1=Monday, 7=Sunday
+	WindowRules       []WindowRule `json:"window_rules,omitempty"`
+	WindowCommand     string       `json:"window_command,omitempty"` // prints app and title on two lines
+	GitTagBy          string       `json:"git_tag_by,omitempty"`     // "repo" (default) or "branch"
+	GitWindowMinutes  int          `json:"git_window_minutes,omitempty"`
+	ShowMeetings      bool         `json:"show_meetings,omitempty"` // show meetings as their own status in the timeline
+	Schedules         []Schedule   `json:"schedules,omitempty"`
+	BalanceStart      string       `json:"balance_start,omitempty"`       // first day counted in the flextime balance
+	HeatmapDays       int          `json:"heatmap_days,omitempty"`        // window of the hour-of-day heatmap
+	WindowDays        int          `json:"window_days,omitempty"`         // days of focused-window history to keep
+	FocusGapMinutes   int          `json:"focus_gap_minutes,omitempty"`   // idle gaps shorter than this don't end a focus session
+	MinSessionMinutes int          `json:"min_session_minutes,omitempty"` // shorter working runs are not sessions
+	DeepWorkMinutes   int          `json:"deep_work_minutes,omitempty"`   // sessions at least this long count as deep work
+	TagAllocation     string       `json:"tag_allocation,omitempty"`      // "full" (default) or "split" time between a block's tags
+	PomodoroMinutes   int          `json:"pomodoro_minutes,omitempty"`
+	ShortBreakMinutes int          `json:"short_break_minutes,omitempty"`
+	LongBreakMinutes  int          `json:"long_break_minutes,omitempty"`
+	LongBreakEvery    int          `json:"long_break_every,omitempty"`       // pomodoros before a long break
+	BreakReminder     int          `json:"break_reminder_minutes,omitempty"` // warn after this much continuous work, 0 to disable
+	NotifyCommand     string       `json:"notify_command,omitempty"`         // run by the command sink, or instead of the desktop by default
+	NotifySinks       []string     `json:"notify_sinks,omitempty"`           // desktop, stdout and/or command
+	QuietHours        string       `json:"quiet_hours,omitempty"`            // HH:MM-HH:MM without tracker notifications
+	EndOfDay          string       `json:"end_of_day,omitempty"`             // HH:MM after which working time raises a notification
+	IdleFailures      int          `json:"idle_failures,omitempty"`          // failed idle reads in a row before notifying
+	Hooks             []Hook       `json:"hooks,omitempty"`
+	Device            string       `json:"device,omitempty"`   // name of this machine in merged stores, default the host name
+	SyncDir           string       `json:"sync_dir,omitempty"` // shared folder the tracker syncs with
+}
+
+// Hook runs a shell command with the event as JSON on stdin, or POSTs it to a URL.
+type Hook struct {
+	Event   string `json:"event"` // working, idle, session_start, session_stop, tag, goal or * for all
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Timeout int    `json:"timeout_seconds,omitempty"` // per attempt, default 10
+	Retries int    `json:"retries,omitempty"`         // extra attempts after a failure
+}
+
+// Schedule sets the goals from From onwards, until the next schedule starts.
+// Without Weekdays the daily goal and work days above apply.
+type Schedule struct {
+	From           string         `json:"from"`               // 2006-01-02
+	Weekdays       map[string]int `json:"weekdays,omitempty"` // "mon".."sun" -> minutes; missing days are off
+	WeeklyMinutes  int            `json:"weekly_minutes,omitempty"`
+	MonthlyMinutes int            `json:"monthly_minutes,omitempty"`
 }
 
 type Range struct {
-	Start  int64  `json:"start"`
-	End    int64  `json:"end"`
-	Status int    `json:"status"`
-	Tag    string `json:"tag,omitempty"`
-	Note   string `json:"note,omitempty"`
+	Start    int64    `json:"start"`
+	End      int64    `json:"end"`
+	Status   int      `json:"status"`
+	Tags     []string `json:"tags,omitempty"`
+	Tag      string   `json:"tag,omitempty"` // single tag of older stores, moved to Tags on load
+	Note     string   `json:"note,omitempty"`
+	Source   string   `json:"source,omitempty"`   // empty for manual tags, otherwise what applied it
+	TagOnly  bool     `json:"tag_only,omitempty"` // carries a tag/note without affecting status
+	Override bool     `json:"override,omitempty"` // manual status that wins over tracked data
+	Split    bool     `json:"split,omitempty"`    // zero-length marker keeping timeline blocks apart at Start
+	Device   string   `json:"device,omitempty"`   // machine it came from, empty for the store's own
+	Modified int64    `json:"modified,omitempty"` // when it was last edited, to resolve merge conflicts
 }
 
 type Store struct {
-	Bins   map[string]int `json:"bins"`
-	Ranges []Range        `json:"ranges"`
-	Config Config         `json:"config"`
-	Tags   []string       `json:"tags,omitempty"`
+	Bins        map[string]int     `json:"bins"`
+	Ranges      []Range            `json:"ranges"`
+	Config      Config             `json:"config"`
+	Tags        []string           `json:"tags,omitempty"`
+	Windows     []WindowSpan       `json:"windows,omitempty"`
+	Sessions    []Session          `json:"sessions,omitempty"`
+	GitEvents   []GitEvent         `json:"git_events,omitempty"`
+	Meetings    []Meeting          `json:"meetings,omitempty"`
+	DaysOff     []DayOff           `json:"days_off,omitempty"`
+	Adjustments []Adjustment       `json:"balance_adjustments,omitempty"`
+	History     []Change           `json:"history,omitempty"`
+	TagMeta     map[string]TagMeta `json:"tag_meta,omitempty"`
+	Pomodoros   []Pomodoro         `json:"pomodoros,omitempty"`
+	Notified    map[string]int64   `json:"notified,omitempty"` // notification key -> when it was sent
+	Cleared     []Range            `json:"cleared,omitempty"`  // spans whose tags were removed, so merges don't restore them
+	Removed     map[string]int64   `json:"removed,omitempty"`  // removedKey of a deleted day off, meeting or tag -> when, for merges
+}
+
+// Pomodoro is a completed work phase, logged against the tags worked on in it.
+type Pomodoro struct {
+	Start  int64    `json:"start"`
+	End    int64    `json:"end"`
+	Tags   []string `json:"tags,omitempty"`
+	Rested int      `json:"rested,omitempty"` // minutes away from the computer in the following break
+}
+
+// TagMeta holds display settings for a tag; subtags inherit the color.
+type TagMeta struct {
+	Color    string `json:"color,omitempty"` // #RRGGBB or an ANSI color number
+	Archived bool   `json:"archived,omitempty"`
+	Modified int64  `json:"modified,omitempty"` // when it was last set, to resolve merge conflicts
+}
+
+// Change records one edit of the ranges so it can be undone and redone.
+type Change struct {
+	Time    int64   `json:"time"`
+	Action  string  `json:"action"`
+	Removed []Range `json:"removed,omitempty"`
+	Added   []Range `json:"added,omitempty"`
+	Undone  bool    `json:"undone,omitempty"`
+}
+
+// Adjustment is a manual correction of the flextime balance, e.g. overtime paid out.
+type Adjustment struct {
+	Date    string `json:"date"`
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// DayOff removes a day (or half of it) from the goals: holidays, vacation, sick days.
+type DayOff struct {
+	Date     string `json:"date"` // 2006-01-02
+	Reason   string `json:"reason,omitempty"`
+	Half     bool   `json:"half,omitempty"`
+	Modified int64  `json:"modified,omitempty"` // when it was last set, to resolve merge conflicts
+}
+
+// Meeting is an accepted calendar event imported by `calendar sync`.
+type Meeting struct {
+	Calendar string `json:"calendar"`
+	UID      string `json:"uid,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	Tag      string `json:"tag,omitempty"`
+	Modified int64  `json:"modified,omitempty"` // when it was last imported, to resolve merge conflicts
+}
+
+// GitEvent is reported by the installed git hooks.
+type GitEvent struct {
+	Time   int64  `json:"time"`
+	Kind   string `json:"kind"` // commit or checkout
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// WindowSpan is a run of samples during which the same window had focus.
+type WindowSpan struct {
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	App   string `json:"app"`
+	Title string `json:"title,omitempty"`
+	Dir   string `json:"dir,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+}
+
+// WindowRule tags working bins whose focused window matches App and Title (both regexes, empty matches anything).
+type WindowRule struct {
+	App   string `json:"app,omitempty"`
+	Title string `json:"title,omitempty"`
+	Tag   string `json:"tag"`
+}
+
+type ActiveWindow struct {
+	App   string
+	Title string
+	PID   int
+	Dir   string // working directory of the focused process tree
+	Repo  string // git repository containing Dir
+}
+
+// Session is an explicitly started piece of work; End is 0 while it runs.
+type Session struct {
+	Name  string `json:"name"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end,omitempty"`
+}
+
+// Rule assigns Tag and Note to working bins matching every condition that is set.
+// Higher Priority wins; ties go to the rule listed first.
+type Rule struct {
+	Name     string `json:"name,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Tag      string `json:"tag"`
+	Note     string `json:"note,omitempty"`
+	From     string `json:"from,omitempty"` // HH:MM, inclusive
+	To       string `json:"to,omitempty"`   // HH:MM, exclusive; may wrap past midnight
+	Weekdays []int  `json:"weekdays,omitempty"`
+	App      string `json:"app,omitempty"`     // regex
+	Title    string `json:"title,omitempty"`   // regex
+	Dir      string `json:"dir,omitempty"`     // regex on the working directory
+	Repo     string `json:"repo,omitempty"`    // regex on the git repository name
+	Session  string `json:"session,omitempty"` // regex on the running session name
+
+	from, to                         int
+	app, title, dir, repo, sessionRe *regexp.Regexp
+}
+
+// RuleContext is what a rule can see about a single bin.
+type RuleContext struct {
+	Time    time.Time
+	App     string
+	Title   string
+	Dir     string
+	Repo    string
+	Session string
 }
 
 type TimelineBlock struct {
@@ -53,21 +256,49 @@ type TimelineBlock struct {
 	duration int
 	tag      string
 	note     string
-	rangeIdx int // Index in ranges array, -1 if from bins
+	override bool
+	rangeIdx int // Index of the tag range in ranges array, -1 if untagged
 }
 
 type dashboardModel struct {
-	store                 *Store
-	filePath              string
-	width                 int
-	height                int
-	selectedTimeline      int  // Currently selected timeline item
-	showTagDialog         bool // Whether tag dialog is open
-	tagInput              string
-	availableTags         []string
-	selectedTag           int
-	timelineBlocks        []TimelineBlock
-	showingTagSuggestions bool
+	store            *Store
+	filePath         string
+	balance          [3]int // today, week and all-time flextime, replayed on load and tick only
+	width            int
+	height           int
+	selectedTimeline int  // Currently selected timeline item
+	showTagDialog    bool // Whether the block editor is open
+	tagInput         textInput
+	noteInput        textInput
+	statusOverride   int // -1 keeps the tracked status, otherwise 0 idle / 1 working
+	editorField      int // focused editor field, see editorFields
+	availableTags    []string
+	tagRank          map[string]float64 // frecency of each tag for the block being edited
+	selectedTag      int                // highlighted suggestion, -1 for none
+	timelineBlocks   []TimelineBlock
+	day              time.Time // day shown in the timeline, zero follows today
+	showDateDialog   bool
+	dateInput        textInput
+	showSplitDialog  bool
+	splitInput       textInput
+	anchor           int          // start of the range selection, -1 when off
+	marked           map[int]bool // blocks selected one by one
+	editTargets      []int        // blocks the open editor applies to
+	message          string       // result of the last undo/redo, shown in the footer
+	activeView       int          // one of viewOverview, viewCalendar, viewTags, viewTrends
+	calDay           time.Time    // day selected in the calendar view
+	tagPeriod        int          // index into tagPeriods
+	tagLevel         int          // tag explorer depth: tags, days, blocks
+	tagCursor        [3]int
+	tagSel           string
+	tagDay           string
+	tagData          map[string]map[string]int // tag -> day -> working minutes
+	tagCollapsed     map[string]bool           // tag explorer nodes with hidden children
+	tagDepth         int                       // levels expanded in the tag analytics box, 0 for all
+	tagFilter        string                    // tag query limiting the explorer, e.g. "acme AND meeting"
+	editingFilter    bool
+	filterInput      textInput
+	pomo             pomodoro // toggled with p; also raises break reminders
 }
 
 var (
@@ -107,6 +338,21 @@ var (
 			Foreground(lipgloss.Color("#FFFFFF")).
 			Bold(true)
 
+	markedStyle = lipgloss.NewStyle().
+			Background(lipgloss.Color("#3C3470")).
+			Foreground(lipgloss.Color("#FFFFFF"))
+
+	offStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#5DADE2"))
+
+	meetingStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#4A90E2")).
+			Bold(true)
+
+	noteStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#A0A0A0")).
+			Italic(true)
+
 	tagStyle = lipgloss.NewStyle().
 			Background(lipgloss.Color("#F7DC6F")).
 			Foreground(lipgloss.Color("#000000")).
@@ -128,7 +374,11 @@ func compactBins(s *Store) {
 	if len(s.Bins) < 50 {
 		return
 	}
+	flushBins(s)
+}
 
+// flushBins moves all bins into status ranges.
+func flushBins(s *Store) {
 	var times []time.Time
 	for k := range s.Bins {
 		if ts, err := strconv.ParseInt(k, 10, 64); err == nil {
@@ -182,63 +432,624 @@ func tickCmd() tea.Cmd {
 	})
 }
 
+// textInput is a single-line editor holding runes, so the cursor stays on
+// character boundaries for unicode input and pasted text.
+type textInput struct {
+	value  []rune
+	cursor int
+}
+
+var cursorStyle = lipgloss.NewStyle().Reverse(true)
+
+func newTextInput(value string) textInput {
+	r := []rune(value)
+	return textInput{value: r, cursor: len(r)}
+}
+
+func (t textInput) String() string { return string(t.value) }
+
+func (t *textInput) insert(runes []rune) {
+	var clean []rune
+	for _, r := range runes {
+		if r == '\n' || r == '\r' || r == '\t' {
+			r = ' '
+		}
+		if r >= ' ' && r != 0x7f {
+			clean = append(clean, r)
+		}
+	}
+	t.value = append(t.value[:t.cursor], append(clean, t.value[t.cursor:]...)...)
+	t.cursor += len(clean)
+}
+
+// update applies an editing key and reports whether the input used it.
+func (t *textInput) update(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyRunes:
+		if msg.Alt {
+			return false
+		}
+		t.insert(msg.Runes)
+	case tea.KeySpace:
+		t.insert([]rune{' '})
+	case tea.KeyBackspace, tea.KeyCtrlH:
+		if t.cursor > 0 {
+			t.value = append(t.value[:t.cursor-1], t.value[t.cursor:]...)
+			t.cursor--
+		}
+	case tea.KeyDelete, tea.KeyCtrlD:
+		if t.cursor < len(t.value) {
+			t.value = append(t.value[:t.cursor], t.value[t.cursor+1:]...)
+		}
+	case tea.KeyLeft, tea.KeyCtrlB:
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case tea.KeyRight, tea.KeyCtrlF:
+		if t.cursor < len(t.value) {
+			t.cursor++
+		}
+	case tea.KeyHome, tea.KeyCtrlA:
+		t.cursor = 0
+	case tea.KeyEnd, tea.KeyCtrlE:
+		t.cursor = len(t.value)
+	case tea.KeyCtrlU:
+		t.value = t.value[t.cursor:]
+		t.cursor = 0
+	case tea.KeyCtrlK:
+		t.value = t.value[:t.cursor]
+	case tea.KeyCtrlW:
+		i := t.cursor
+		for i > 0 && t.value[i-1] == ' ' {
+			i--
+		}
+		for i > 0 && t.value[i-1] != ' ' {
+			i--
+		}
+		t.value = append(t.value[:i], t.value[t.cursor:]...)
+		t.cursor = i
+	default:
+		return false
+	}
+	return true
+}
+
+func (t textInput) view(focused bool) string {
+	if !focused {
+		return string(t.value)
+	}
+	if t.cursor >= len(t.value) {
+		return string(t.value) + cursorStyle.Render(" ")
+	}
+	return string(t.value[:t.cursor]) + cursorStyle.Render(string(t.value[t.cursor])) + string(t.value[t.cursor+1:])
+}
+
+// fuzzyScore reports whether the characters of pattern appear in order in s,
+// ignoring case, and how well: matches at word starts and in runs score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(strings.ToLower(s))
+	if len(p) == 0 {
+		return 0, true
+	}
+	score, pi, last := 0, 0, -2
+	for i := 0; i < len(r) && pi < len(p); i++ {
+		if r[i] != p[pi] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 4
+		}
+		if i == 0 || strings.ContainsRune(" -_/:.", r[i-1]) {
+			score += 6
+		}
+		last = i
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	return score*10 - len(r), true
+}
+
+// fuzzyTags returns the tags matching input, best first. rank breaks ties and
+// lifts often and recently used tags above slightly better text matches.
+func fuzzyTags(tags []string, input string, rank map[string]float64) []string {
+	type scored struct {
+		tag   string
+		score float64
+	}
+	var matches []scored
+	for _, t := range tags {
+		if score, ok := fuzzyScore(input, t); ok {
+			matches = append(matches, scored{t, float64(score) + 30*math.Log1p(rank[t])})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.tag
+	}
+	return out
+}
+
+var (
+	editorFields   = []string{"Tag", "Note", "Status"}
+	statusChoices  = []int{-1, 1, 0}
+	maxSuggestions = 5
+)
+
+func statusLabel(status int) string {
+	switch status {
+	case 1:
+		return "working"
+	case 0:
+		return "idle"
+	}
+	return "as tracked"
+}
+
+// selectedBlocks returns the indexes of the selected blocks in order,
+// or just the block under the cursor when nothing is selected.
+func (m *dashboardModel) selectedBlocks() []int {
+	var out []int
+	for i := range m.timelineBlocks {
+		if m.isSelected(i) {
+			out = append(out, i)
+		}
+	}
+	if len(out) == 0 && m.selectedTimeline < len(m.timelineBlocks) {
+		out = []int{m.selectedTimeline}
+	}
+	return out
+}
+
+func (m *dashboardModel) isSelected(i int) bool {
+	if m.marked[i] {
+		return true
+	}
+	if m.anchor < 0 {
+		return false
+	}
+	lo, hi := m.anchor, m.selectedTimeline
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return i >= lo && i <= hi
+}
+
+func (m *dashboardModel) mark(i int, on bool) {
+	if m.marked == nil {
+		m.marked = map[int]bool{}
+	}
+	if on {
+		m.marked[i] = true
+	} else {
+		delete(m.marked, i)
+	}
+}
+
+func (m *dashboardModel) clearSelection() {
+	m.anchor = -1
+	m.marked = nil
+}
+
+// openEditor fills the block editor with the selected blocks, prefilling
+// fields only where all of them agree.
+func (m *dashboardModel) openEditor() {
+	m.editTargets = m.selectedBlocks()
+	m.showTagDialog = true
+	m.editorField = 0
+	for n, i := range m.editTargets {
+		block := m.timelineBlocks[i]
+		tag, note := block.tag, block.note
+		if block.rangeIdx < 0 {
+			// meeting summaries are shown as notes but belong to the calendar
+			tag, note = "", ""
+		}
+		status := -1
+		if idx := overrideAt(m.store, block.start); idx >= 0 {
+			status = m.store.Ranges[idx].Status
+		}
+		if n == 0 {
+			m.tagInput, m.noteInput, m.statusOverride = newTextInput(tag), newTextInput(note), status
+			continue
+		}
+		if tag != m.tagInput.String() {
+			m.tagInput = newTextInput("")
+		}
+		if note != m.noteInput.String() {
+			m.noteInput = newTextInput("")
+		}
+		if status != m.statusOverride {
+			m.statusOverride = -1
+		}
+	}
+	m.tagRank = tagRanks(m.store, m.timelineBlocks[m.editTargets[0]].start)
+	m.updateSuggestions()
+}
+
+// tagRanks scores tags by frecency for a block starting at at: every tagged
+// range adds one plus its hours, halved for every two weeks of age, and counts
+// double when it was used within an hour of the same time of day.
+func tagRanks(s *Store, at time.Time) map[string]float64 {
+	now := time.Now()
+	clock := at.Hour()*60 + at.Minute()
+	ranks := map[string]float64{}
+	for _, r := range s.Ranges {
+		if len(r.Tags) == 0 {
+			continue
+		}
+		start, end := time.Unix(r.Start, 0), time.Unix(r.End, 0)
+		weight := (1 + end.Sub(start).Hours()) * math.Pow(0.5, now.Sub(end).Hours()/24/14)
+		from := start.Hour()*60 + start.Minute()
+		if clock+60 >= from && clock-60 <= from+int(end.Sub(start).Minutes()) {
+			weight *= 2
+		}
+		for _, tag := range r.Tags {
+			ranks[tag] += weight
+		}
+	}
+	return ranks
+}
+
+// knownTags lists the saved tags plus any tag used on a range, sorted.
+func knownTags(s *Store) []string {
+	tags := append([]string{}, s.Tags...)
+	for _, r := range s.Ranges {
+		for _, tag := range r.Tags {
+			if !contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// parseTags splits a comma-separated list into normalized, unique, sorted tags.
+func parseTags(list string) []string {
+	var tags []string
+	for _, t := range strings.Split(list, ",") {
+		if t = normalizeTag(t); t != "" && !contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func joinTags(tags []string) string {
+	return strings.Join(tags, ", ")
+}
+
+// updateSuggestions completes the tag being typed after the last comma,
+// leaving out tags already in the list.
+func (m *dashboardModel) updateSuggestions() {
+	input := m.tagInput.String()
+	done := parseTags(input[:strings.LastIndex(input, ",")+1])
+	m.availableTags = nil
+	for _, t := range fuzzyTags(knownTags(m.store), m.typedTag(), m.tagRank) {
+		if !contains(done, t) && !m.store.TagMeta[t].Archived && len(m.availableTags) < maxSuggestions {
+			m.availableTags = append(m.availableTags, t)
+		}
+	}
+	m.selectedTag = -1
+}
+
+// typedTag returns the tag being typed, after the last comma.
+func (m *dashboardModel) typedTag() string {
+	input := m.tagInput.String()
+	return strings.TrimSpace(input[strings.LastIndex(input, ",")+1:])
+}
+
+// acceptSuggestion replaces the tag being typed with the selected suggestion.
+func (m *dashboardModel) acceptSuggestion() {
+	input := m.tagInput.String()
+	prefix := strings.TrimSpace(input[:strings.LastIndex(input, ",")+1])
+	if prefix != "" {
+		prefix += " "
+	}
+	m.tagInput = newTextInput(prefix + m.availableTags[m.selectedTag])
+	m.updateSuggestions()
+}
+
 func (m dashboardModel) handleTagDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	suggesting := m.editorField == 0 && len(m.availableTags) > 0
 	switch msg.String() {
 	case "esc":
 		m.showTagDialog = false
-		m.showingTagSuggestions = false
+		return m, nil
 	case "enter":
-		if m.showingTagSuggestions && m.selectedTag < len(m.availableTags) {
-			m.tagInput = m.availableTags[m.selectedTag]
-			m.showingTagSuggestions = false
-		} else {
-			// Save the tag
-			if m.selectedTimeline < len(m.timelineBlocks) {
-				block := m.timelineBlocks[m.selectedTimeline]
-				if err := m.saveTag(block, m.tagInput); err == nil {
-					// Add tag to available tags if new
-					if m.tagInput != "" && !contains(m.store.Tags, m.tagInput) {
-						m.store.Tags = append(m.store.Tags, m.tagInput)
-						sort.Strings(m.store.Tags)
-					}
-					saveStore(m.filePath, m.store)
-					// Rebuild timeline blocks to reflect the changes
-					m.buildTimelineBlocks()
-				}
+		if suggesting && m.selectedTag >= 0 {
+			m.acceptSuggestion()
+			return m, nil
+		}
+		tags := parseTags(m.tagInput.String())
+		note := strings.TrimSpace(m.noteInput.String())
+		// All selected blocks are changed together and saved once.
+		before := cloneRanges(m.store.Ranges)
+		for _, i := range m.editTargets {
+			block := m.timelineBlocks[i]
+			m.saveTag(block, tags, note)
+			setOverride(m.store, block.start.Unix(), block.end.Unix(), m.statusOverride)
+		}
+		mergeRanges(m.store)
+		action := fmt.Sprintf("edit %d blocks", len(m.editTargets))
+		if first := m.timelineBlocks[m.editTargets[0]]; len(m.editTargets) == 1 {
+			action = fmt.Sprintf("edit %s %s-%s", first.start.Format("Jan 2"), first.start.Format("15:04"), first.end.Format("15:04"))
+		}
+		recordChange(m.store, action, before)
+		// Add tags to available tags if new
+		for _, tag := range tags {
+			if !contains(m.store.Tags, tag) {
+				m.store.Tags = append(m.store.Tags, tag)
 			}
-			m.showTagDialog = false
-			m.showingTagSuggestions = false
 		}
+		sort.Strings(m.store.Tags)
+		saveStore(m.filePath, m.store)
+		if len(tags) > 0 && len(m.store.Config.Hooks) > 0 {
+			e := newHookEvent(m.store, "tag", time.Now())
+			e.Tags = tags
+			go fireHooks(m.store.Config.Hooks, m.filePath, e)
+		}
+		// Rebuild timeline blocks to reflect the changes
+		m.buildTimelineBlocks()
+		m.clearSelection()
+		m.showTagDialog = false
+		return m, nil
+	case "tab":
+		if suggesting && m.selectedTag < 0 && m.typedTag() != "" && m.typedTag() != m.availableTags[0] {
+			// complete the top match and stay, so another tag can follow
+			m.selectedTag = 0
+			m.acceptSuggestion()
+			return m, nil
+		}
+		if suggesting && m.selectedTag >= 0 {
+			m.acceptSuggestion()
+		}
+		m.editorField = (m.editorField + 1) % len(editorFields)
+		return m, nil
+	case "shift+tab":
+		m.editorField = (m.editorField + len(editorFields) - 1) % len(editorFields)
+		return m, nil
 	case "up":
-		if m.showingTagSuggestions && m.selectedTag > 0 {
-			m.selectedTag--
+		if suggesting {
+			if m.selectedTag >= 0 {
+				m.selectedTag--
+			}
+		} else if m.editorField > 0 {
+			m.editorField--
 		}
+		return m, nil
 	case "down":
-		if m.showingTagSuggestions && m.selectedTag < len(m.availableTags)-1 {
+		if suggesting && m.selectedTag < len(m.availableTags)-1 {
 			m.selectedTag++
+		} else if !suggesting && m.editorField < len(editorFields)-1 {
+			m.editorField++
 		}
-	case "tab":
-		if len(m.availableTags) > 0 {
-			m.showingTagSuggestions = !m.showingTagSuggestions
-			if m.showingTagSuggestions {
-				m.selectedTag = 0
-			}
+		return m, nil
+	}
+
+	switch m.editorField {
+	case 0:
+		if m.tagInput.update(msg) {
+			m.updateSuggestions()
 		}
-	case "backspace":
-		if len(m.tagInput) > 0 {
-			m.tagInput = m.tagInput[:len(m.tagInput)-1]
+	case 1:
+		m.noteInput.update(msg)
+	case 2:
+		i := 0
+		for i < len(statusChoices) && statusChoices[i] != m.statusOverride {
+			i++
 		}
+		switch msg.String() {
+		case "left", "h":
+			m.statusOverride = statusChoices[(i+len(statusChoices)-1)%len(statusChoices)]
+		case "right", "l", " ":
+			m.statusOverride = statusChoices[(i+1)%len(statusChoices)]
+		}
+	}
+	return m, nil
+}
+
+// viewRange returns the bounds of the day shown in the timeline; today ends now.
+func (m *dashboardModel) viewRange() (start, end time.Time) {
+	if m.day.IsZero() {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), now
+	}
+	return m.day, m.day.AddDate(0, 0, 1)
+}
+
+// setDay moves the timeline to the day of d, never past today.
+func (m *dashboardModel) setDay(d time.Time) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	d = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, now.Location())
+	if d.Before(today) {
+		m.day = d
+	} else {
+		m.day = time.Time{}
+	}
+	m.selectedTimeline = 0
+	m.clearSelection()
+	m.buildTimelineBlocks()
+}
+
+// parseDayInput understands YYYY-MM-DD, today, yesterday and -N (days ago).
+func parseDayInput(input string) (time.Time, error) {
+	now := time.Now()
+	switch input = strings.TrimSpace(strings.ToLower(input)); {
+	case input == "" || input == "today":
+		return now, nil
+	case input == "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case strings.HasPrefix(input, "-"):
+		n, err := strconv.Atoi(input[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	return time.ParseInLocation("2006-01-02", input, now.Location())
+}
+
+func (m dashboardModel) handleDateDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.showDateDialog = false
+	case "enter":
+		if d, err := parseDayInput(m.dateInput.String()); err == nil {
+			m.setDay(d)
+			m.showDateDialog = false
+		}
+	default:
+		m.dateInput.update(msg)
+	}
+	return m, nil
+}
+
+func (m dashboardModel) handleFilterInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.editingFilter = false
+	case "enter":
+		m.tagFilter = strings.TrimSpace(m.filterInput.String())
+		m.editingFilter = false
+		m.tagLevel = 0
+		m.tagCursor = [3]int{}
+		m.loadTagData()
 	default:
-		if len(msg.String()) == 1 {
-			m.tagInput += msg.String()
+		m.filterInput.update(msg)
+	}
+	return m, nil
+}
+
+func (m dashboardModel) handleSplitDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.showSplitDialog = false
+	case "enter":
+		block := m.timelineBlocks[m.selectedTimeline]
+		mins, err := parseTimeToMinutes(m.splitInput.String())
+		if err != nil {
+			return m, nil
+		}
+		day := time.Date(block.start.Year(), block.start.Month(), block.start.Day(), 0, 0, 0, 0, block.start.Location())
+		at := floorToBin(day.Add(time.Duration(mins) * time.Minute))
+		if !at.After(block.start) || !at.Before(block.end) {
+			return m, nil
 		}
+		before := cloneRanges(m.store.Ranges)
+		splitBlock(m.store, block, at)
+		recordChange(m.store, "split block at "+at.Format("Jan 2 15:04"), before)
+		saveStore(m.filePath, m.store)
+		m.buildTimelineBlocks()
+		m.showSplitDialog = false
+	default:
+		m.splitInput.update(msg)
 	}
 	return m, nil
 }
 
+// splitBlock keeps the parts of block before and after at apart, so each
+// side can be tagged on its own.
+func splitBlock(s *Store, block TimelineBlock, at time.Time) {
+	s.Ranges = append(s.Ranges, Range{Start: at.Unix(), End: at.Unix(), Split: true})
+	if block.rangeIdx >= 0 {
+		right := s.Ranges[block.rangeIdx]
+		right.Start = at.Unix()
+		s.Ranges[block.rangeIdx].End = at.Unix()
+		s.Ranges = append(s.Ranges, right)
+	}
+}
+
+// splitAt reports whether the timeline was split by hand at t.
+func splitAt(s *Store, t int64) bool {
+	return slices.ContainsFunc(s.Ranges, func(r Range) bool { return r.Split && r.Start == t })
+}
+
+// moveBoundary shifts the boundary between block i and the next one by one bin,
+// later when delta > 0. The bin that changes sides takes on the tag, note and
+// status of the block it joins.
+func (m *dashboardModel) moveBoundary(i, delta int) {
+	if i < 0 || i+1 >= len(m.timelineBlocks) {
+		return
+	}
+	from, to := m.timelineBlocks[i+1], m.timelineBlocks[i]
+	span := TimelineBlock{start: to.end, end: to.end.Add(binMinutes * time.Minute)}
+	if delta < 0 {
+		from, to = to, from
+		span = TimelineBlock{start: to.start.Add(-binMinutes * time.Minute), end: to.start}
+	}
+
+	selected := m.timelineBlocks[m.selectedTimeline].start
+	if m.selectedTimeline == i+1 && delta > 0 {
+		selected = selected.Add(binMinutes * time.Minute)
+	} else if m.selectedTimeline == i+1 {
+		selected = selected.Add(-binMinutes * time.Minute)
+	}
+
+	before := cloneRanges(m.store.Ranges)
+	var tagRange *Range
+	if to.rangeIdx >= 0 {
+		r := m.store.Ranges[to.rangeIdx]
+		r.Start, r.End = span.start.Unix(), span.end.Unix()
+		tagRange = &r
+	}
+	if tagRange != nil {
+		cutTagRanges(m.store, span.start.Unix(), span.end.Unix(), func(Range) bool { return true })
+		m.store.Ranges = append(m.store.Ranges, *tagRange)
+	} else {
+		clearTags(m.store, span.start.Unix(), span.end.Unix())
+	}
+	status := -1
+	if idx := overrideAt(m.store, to.start); idx >= 0 {
+		status = m.store.Ranges[idx].Status
+	} else if to.status != from.status {
+		status = min(to.status, 1) // a meeting's bins count as working
+	}
+	setOverride(m.store, span.start.Unix(), span.end.Unix(), status)
+	boundary := m.timelineBlocks[i].end.Unix()
+	for k, r := range m.store.Ranges {
+		if r.Split && r.Start == boundary {
+			m.store.Ranges[k].Start = boundary + int64(delta)*binMinutes*60
+			m.store.Ranges[k].End = m.store.Ranges[k].Start
+		}
+	}
+	mergeRanges(m.store)
+	recordChange(m.store, "move boundary at "+span.start.Format("Jan 2 15:04"), before)
+	saveStore(m.filePath, m.store)
+	m.buildTimelineBlocks()
+
+	for j, b := range m.timelineBlocks {
+		if !selected.Before(b.start) && selected.Before(b.end) {
+			m.selectedTimeline = j
+		}
+	}
+}
+
+// stepHistory undoes or redoes one change and saves the store.
+func (m *dashboardModel) stepHistory(step func(*Store) (*Change, error), verb string) {
+	c, err := step(m.store)
+	if c != nil {
+		saveStore(m.filePath, m.store)
+		m.clearSelection()
+		m.buildTimelineBlocks()
+		if m.selectedTimeline >= len(m.timelineBlocks) {
+			m.selectedTimeline = max(len(m.timelineBlocks)-1, 0)
+		}
+		m.message = verb + ": " + c.Action
+	}
+	if err != nil {
+		m.message = err.Error()
+	}
+}
+
 func (m *dashboardModel) buildTimelineBlocks() {
-	now := time.Now()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start, now := m.viewRange()
 	bins := fetchBins(m.store, start, now)
 
 	// Create full sequence from midnight to now
@@ -255,14 +1066,27 @@ func (m *dashboardModel) buildTimelineBlocks() {
 	for t, v := range bins {
 		status[t] = v
 	}
+	ix := indexBins(m.store, start, now)
+	meetings := map[time.Time]*Meeting{}
+	for _, t := range seq {
+		if mt := ix.meetingAt(t); mt != nil {
+			meetings[t] = mt
+			if m.store.Config.ShowMeetings && ix.overrideAt(t) < 0 {
+				status[t] = meetingStatus
+			}
+		}
+	}
 
 	// Build merged blocks
 	m.timelineBlocks = nil
 	for i := 0; i < len(seq); {
 		startBin := seq[i]
 		st := status[startBin]
+		tagIdx := ix.tagRangeAt(startBin)
+		overrideIdx := ix.overrideAt(startBin)
 		j := i
-		for j < len(seq) && status[seq[j]] == st {
+		for j < len(seq) && (j == i || !ix.splitAt(seq[j])) && status[seq[j]] == st && ix.tagRangeAt(seq[j]) == tagIdx &&
+			ix.overrideAt(seq[j]) == overrideIdx && meetings[seq[j]] == meetings[startBin] {
 			j++
 		}
 		endBin := seq[j-1].Add(binMinutes * time.Minute)
@@ -271,16 +1095,13 @@ func (m *dashboardModel) buildTimelineBlocks() {
 		// Find matching range for tag info
 		tag := ""
 		note := ""
-		rangeIdx := -1
-		for idx, r := range m.store.Ranges {
-			rStart := time.Unix(r.Start, 0)
-			rEnd := time.Unix(r.End, 0)
-			if !startBin.Before(rStart) && startBin.Before(rEnd) {
-				tag = r.Tag
-				note = r.Note
-				rangeIdx = idx
-				break
-			}
+		rangeIdx := tagIdx
+		if rangeIdx >= 0 {
+			tag = joinTags(m.store.Ranges[rangeIdx].Tags)
+			note = m.store.Ranges[rangeIdx].Note
+		} else if mt := meetings[startBin]; mt != nil {
+			tag = mt.Tag
+			note = mt.Summary
 		}
 
 		m.timelineBlocks = append(m.timelineBlocks, TimelineBlock{
@@ -290,6 +1111,7 @@ func (m *dashboardModel) buildTimelineBlocks() {
 			duration: duration,
 			tag:      tag,
 			note:     note,
+			override: overrideIdx >= 0,
 			rangeIdx: rangeIdx,
 		})
 
@@ -297,31 +1119,283 @@ func (m *dashboardModel) buildTimelineBlocks() {
 	}
 }
 
-func (m *dashboardModel) saveTag(block TimelineBlock, tag string) error {
-	// If this block corresponds to a range, update it
-	if block.rangeIdx >= 0 && block.rangeIdx < len(m.store.Ranges) {
-		m.store.Ranges[block.rangeIdx].Tag = tag
-		return nil
-	}
-
-	// Otherwise, create a new range for this time period
-	newRange := Range{
-		Start:  block.start.Unix(),
-		End:    block.end.Unix(),
-		Status: block.status,
-		Tag:    tag,
+// saveTag replaces the tags over the block's span with tags and note; the
+// caller merges the resulting ranges with mergeRanges.
+func (m *dashboardModel) saveTag(block TimelineBlock, tags []string, note string) {
+	start, end := block.start.Unix(), block.end.Unix()
+	if len(tags) == 0 && note == "" {
+		clearTags(m.store, start, end)
+		return
 	}
-	m.store.Ranges = append(m.store.Ranges, newRange)
-	return nil
+	cutTagRanges(m.store, start, end, func(Range) bool { return true })
+	m.store.Ranges = append(m.store.Ranges, Range{
+		Start:   start,
+		End:     end,
+		Status:  1,
+		Tags:    tags,
+		Note:    note,
+		TagOnly: true,
+	})
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+// mergeRanges joins touching or overlapping tag-only and override ranges that
+// carry the same values, so bulk edits leave one range per run. Ranges that
+// only touch at a split stay apart.
+func mergeRanges(s *Store) {
+	var out, merge []Range
+	for _, r := range s.Ranges {
+		if r.TagOnly || r.Override {
+			merge = append(merge, r)
+		} else {
+			out = append(out, r)
 		}
 	}
-	return false
+	sort.SliceStable(merge, func(i, j int) bool { return merge[i].Start < merge[j].Start })
+	same := func(a, b Range) bool {
+		return a.TagOnly == b.TagOnly && a.Override == b.Override && a.Status == b.Status &&
+			slices.Equal(a.Tags, b.Tags) && a.Note == b.Note && a.Source == b.Source
+	}
+	for _, r := range merge {
+		joined := false
+		for i := len(out) - 1; i >= 0 && !joined; i-- {
+			if (out[i].TagOnly || out[i].Override) && same(out[i], r) && r.Start <= out[i].End && r.End >= out[i].Start &&
+				!(r.Start == out[i].End && splitAt(s, r.Start)) {
+				out[i].End = max(out[i].End, r.End)
+				joined = true
+			}
+		}
+		if !joined {
+			out = append(out, r)
+		}
+	}
+	s.Ranges = out
+}
+
+// overrideAt returns the index of the manual status range covering the bin at t, or -1.
+func overrideAt(s *Store, t time.Time) int {
+	for i, r := range s.Ranges {
+		if r.Override && !t.Before(time.Unix(r.Start, 0)) && t.Before(time.Unix(r.End, 0)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// setOverride forces [start, end) to status, or back to the tracked status when status is negative.
+func setOverride(s *Store, start, end int64, status int) {
+	cutRanges(s, start, end, func(r Range) bool { return r.Override })
+	if status >= 0 {
+		s.Ranges = append(s.Ranges, Range{Start: start, End: end, Status: status, Override: true})
+	}
+}
+
+func isTagRange(r Range) bool {
+	return r.TagOnly || len(r.Tags) > 0 || r.Note != ""
+}
+
+// tagRangeAt returns the index of the range carrying the tag for the bin at t,
+// preferring manual tags over automatic ones, or -1 if the bin is untagged.
+func tagRangeAt(s *Store, t time.Time) int {
+	auto := -1
+	for i, r := range s.Ranges {
+		if !isTagRange(r) || t.Before(time.Unix(r.Start, 0)) || !t.Before(time.Unix(r.End, 0)) {
+			continue
+		}
+		if r.Source == "" {
+			return i
+		}
+		if auto < 0 {
+			auto = i
+		}
+	}
+	return auto
+}
+
+// applyAutoTag tags a single working bin on behalf of source unless it already
+// carries a tag, growing the previous auto range when it ends at this bin.
+// tag may list several tags separated by commas.
+func applyAutoTag(s *Store, bin time.Time, tag, note, source string) {
+	tags := parseTags(tag)
+	if len(tags) == 0 || tagRangeAt(s, bin) >= 0 {
+		return
+	}
+	end := bin.Add(binMinutes * time.Minute).Unix()
+	for i := len(s.Ranges) - 1; i >= 0; i-- {
+		r := &s.Ranges[i]
+		if r.TagOnly && r.Source == source && slices.Equal(r.Tags, tags) && r.Note == note && r.End == bin.Unix() {
+			r.End = end
+			return
+		}
+	}
+	s.Ranges = append(s.Ranges, Range{
+		Start:   bin.Unix(),
+		End:     end,
+		Status:  1,
+		Tags:    tags,
+		Note:    note,
+		Source:  source,
+		TagOnly: true,
+	})
+}
+
+// clearTags removes every tag over [start, end). The span is remembered in
+// Store.Cleared so merging with another machine's copy doesn't bring them back.
+func clearTags(s *Store, start, end int64) {
+	if !slices.ContainsFunc(s.Ranges, func(r Range) bool { return r.TagOnly && r.Start < end && r.End > start }) {
+		return
+	}
+	cutTagRanges(s, start, end, func(Range) bool { return true })
+	s.Cleared = append(s.Cleared, Range{Start: start, End: end, TagOnly: true, Modified: time.Now().Unix()})
+}
+
+// cutTagRanges removes [start, end) from every tag-only range accepted by match,
+// keeping the parts that stick out on either side.
+func cutTagRanges(s *Store, start, end int64, match func(Range) bool) {
+	cutRanges(s, start, end, func(r Range) bool { return r.TagOnly && match(r) })
+}
+
+func cutRanges(s *Store, start, end int64, match func(Range) bool) {
+	out := s.Ranges[:0:0]
+	for _, r := range s.Ranges {
+		if !match(r) || r.End <= start || r.Start >= end {
+			out = append(out, r)
+			continue
+		}
+		if r.Start < start {
+			left := r
+			left.End = start
+			out = append(out, left)
+		}
+		if r.End > end {
+			right := r
+			right.Start = end
+			out = append(out, right)
+		}
+	}
+	s.Ranges = out
+}
+
+func cloneRanges(rs []Range) []Range {
+	return append([]Range(nil), rs...)
+}
+
+func rangeKey(r Range) string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// recordChange adds the difference between before and the store's current
+// ranges to the history, dropping anything that was undone.
+func recordChange(s *Store, action string, before []Range) {
+	count := map[string]int{}
+	for _, r := range before {
+		count[rangeKey(r)]++
+	}
+	// Stamp new ranges first, so the history holds them as they are stored.
+	stamp := maps.Clone(count)
+	for i, r := range s.Ranges {
+		if k := rangeKey(r); stamp[k] > 0 {
+			stamp[k]--
+		} else if r.TagOnly || r.Override {
+			s.Ranges[i].Modified = time.Now().Unix()
+		}
+	}
+	var added []Range
+	for _, r := range s.Ranges {
+		if k := rangeKey(r); count[k] > 0 {
+			count[k]--
+		} else {
+			added = append(added, r)
+		}
+	}
+	var removed []Range
+	for _, r := range before {
+		if k := rangeKey(r); count[k] > 0 {
+			count[k]--
+			removed = append(removed, r)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	for len(s.History) > 0 && s.History[len(s.History)-1].Undone {
+		s.History = s.History[:len(s.History)-1]
+	}
+	s.History = append(s.History, Change{
+		Time:    time.Now().Unix(),
+		Action:  action,
+		Removed: removed,
+		Added:   added,
+	})
+	if len(s.History) > maxHistory {
+		s.History = s.History[len(s.History)-maxHistory:]
+	}
+}
+
+// swapRanges removes the given ranges from the store and adds others instead.
+// If any of them changed since, the store is left alone: restoring only part
+// of a change would leave overlapping ranges behind.
+func swapRanges(s *Store, remove, add []Range) error {
+	kept := slices.Clone(s.Ranges)
+	missing := 0
+	for _, r := range remove {
+		k := rangeKey(r)
+		i := slices.IndexFunc(kept, func(x Range) bool { return rangeKey(x) == k })
+		if i < 0 {
+			missing++
+			continue
+		}
+		kept = slices.Delete(kept, i, i+1)
+	}
+	if missing > 0 {
+		return fmt.Errorf("%d ranges changed since", missing)
+	}
+	// The restored edit is the latest one now, also for merges.
+	for i, r := range add {
+		if r.TagOnly || r.Override {
+			add[i].Modified = time.Now().Unix()
+		}
+	}
+	s.Ranges = append(kept, add...)
+	return nil
+}
+
+// undoChange reverts the latest change that is not undone yet.
+func undoChange(s *Store) (*Change, error) {
+	for i := len(s.History) - 1; i >= 0; i-- {
+		if c := &s.History[i]; !c.Undone {
+			if err := swapRanges(s, c.Added, c.Removed); err != nil {
+				return nil, fmt.Errorf("can't undo %s: %w", c.Action, err)
+			}
+			c.Undone = true
+			return c, nil
+		}
+	}
+	return nil, errors.New("nothing to undo")
+}
+
+// redoChange applies the oldest undone change again.
+func redoChange(s *Store) (*Change, error) {
+	for i := range s.History {
+		if c := &s.History[i]; c.Undone {
+			if err := swapRanges(s, c.Removed, c.Added); err != nil {
+				return nil, fmt.Errorf("can't redo %s: %w", c.Action, err)
+			}
+			c.Undone = false
+			return c, nil
+		}
+	}
+	return nil, errors.New("nothing to redo")
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
 }
 func (m dashboardModel) Init() tea.Cmd {
 	return tickCmd()
@@ -329,23 +1403,43 @@ func (m dashboardModel) Init() tea.Cmd {
 }
 
 func (m *dashboardModel) renderTagDialog() string {
-	content := "Tag this time block:\n\n"
-	content += fmt.Sprintf("Input: %s\n", m.tagInput)
-
-	if m.showingTagSuggestions && len(m.availableTags) > 0 {
-		content += "\nSuggestions (↑↓ to select):\n"
-		for i, tag := range m.availableTags {
-			if i == m.selectedTag {
-				content += selectedStyle.Render(fmt.Sprintf("  %s", tag)) + "\n"
-			} else {
-				content += fmt.Sprintf("  %s\n", tag)
+	first := m.timelineBlocks[m.editTargets[0]]
+	content := fmt.Sprintf("Edit %s-%s:\n\n", first.start.Format("15:04"), first.end.Format("15:04"))
+	if n := len(m.editTargets); n > 1 {
+		last := m.timelineBlocks[m.editTargets[n-1]]
+		content = fmt.Sprintf("Edit %d blocks between %s and %s:\n\n", n, first.start.Format("15:04"), last.end.Format("15:04"))
+	}
+
+	for i, field := range editorFields {
+		var value string
+		switch i {
+		case 0:
+			value = m.tagInput.view(m.editorField == i)
+		case 1:
+			value = m.noteInput.view(m.editorField == i)
+		case 2:
+			value = "‹ " + statusLabel(m.statusOverride) + " ›"
+		}
+		label := fmt.Sprintf("%-7s", field+":")
+		if i == m.editorField {
+			label = selectedStyle.Render(label)
+		}
+		content += label + " " + value + "\n"
+
+		if i == 0 && m.editorField == 0 {
+			for j, tag := range m.availableTags {
+				if j == m.selectedTag {
+					content += "        " + selectedStyle.Render(tag) + "\n"
+				} else {
+					content += "        " + tag + "\n"
+				}
 			}
 		}
 	}
 
-	content += "\nPress Tab for suggestions, Enter to save, Esc to cancel"
+	content += "\nTab completes the top tag, then moves between fields\n↑↓ pick a tag or field, ←→ change status\nEnter to save, Esc to cancel"
 
-	return dialogStyle.Render(content)
+	return dialogStyle.Width(50).Render(content)
 }
 
 func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -354,26 +1448,129 @@ func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.showTagDialog {
 			return m.handleTagDialog(msg)
 		}
+		if m.showDateDialog {
+			return m.handleDateDialog(msg)
+		}
+		if m.showSplitDialog {
+			return m.handleSplitDialog(msg)
+		}
+		if m.editingFilter {
+			return m.handleFilterInput(msg)
+		}
 
 		switch msg.String() {
-		case "q", "esc", "ctrl+c":
+		case "q", "ctrl+c":
 			return m, tea.Quit
-		case "up", "k":
+		case "tab":
+			m.switchView((m.activeView + 1) % len(viewNames))
+			return m, nil
+		case "shift+tab":
+			m.switchView((m.activeView + len(viewNames) - 1) % len(viewNames))
+			return m, nil
+		case "1", "2", "3", "4":
+			m.switchView(int(msg.String()[0] - '1'))
+			return m, nil
+		}
+		if m.activeView != viewOverview {
+			return m.handleViewKey(msg)
+		}
+
+		switch msg.String() {
+		case "esc":
+			if m.anchor >= 0 || len(m.marked) > 0 {
+				m.clearSelection()
+				return m, nil
+			}
+			return m, tea.Quit
+		case "up", "k", "shift+up":
+			if msg.String() == "shift+up" && m.anchor < 0 {
+				m.anchor = m.selectedTimeline
+			}
 			if m.selectedTimeline > 0 {
 				m.selectedTimeline--
 			}
-		case "down", "j":
+		case "down", "j", "shift+down":
+			if msg.String() == "shift+down" && m.anchor < 0 {
+				m.anchor = m.selectedTimeline
+			}
 			if m.selectedTimeline < len(m.timelineBlocks)-1 {
 				m.selectedTimeline++
 			}
+		case "v":
+			if m.anchor >= 0 {
+				// keep the range selected so further ranges can be added
+				for i := range m.timelineBlocks {
+					if m.isSelected(i) {
+						m.mark(i, true)
+					}
+				}
+				m.anchor = -1
+			} else {
+				m.anchor = m.selectedTimeline
+			}
+		case " ":
+			if m.selectedTimeline < len(m.timelineBlocks) {
+				m.mark(m.selectedTimeline, !m.marked[m.selectedTimeline])
+			}
+		case "a":
+			for i, block := range m.timelineBlocks {
+				if block.tag == "" && block.status != 0 {
+					m.mark(i, true)
+				}
+			}
+		case "left", "h":
+			start, _ := m.viewRange()
+			m.setDay(start.AddDate(0, 0, -1))
+		case "right", "l":
+			if !m.day.IsZero() {
+				m.setDay(m.day.AddDate(0, 0, 1))
+			}
+		case "t":
+			m.setDay(time.Now())
+		case "g":
+			m.showDateDialog = true
+			m.dateInput = newTextInput("")
+		case "s":
+			if m.selectedTimeline < len(m.timelineBlocks) && m.timelineBlocks[m.selectedTimeline].duration > binMinutes {
+				block := m.timelineBlocks[m.selectedTimeline]
+				mid := block.start.Add(time.Duration(block.duration/binMinutes/2*binMinutes) * time.Minute)
+				m.showSplitDialog = true
+				m.splitInput = newTextInput(mid.Format("15:04"))
+			}
+		case "-":
+			if m.tagDepth == 0 {
+				m.tagDepth = maxTagDepth(m.store)
+			}
+			m.tagDepth = max(m.tagDepth-1, 1)
+		case "+", "=":
+			if m.tagDepth > 0 {
+				if m.tagDepth++; m.tagDepth >= maxTagDepth(m.store) {
+					m.tagDepth = 0
+				}
+			}
+		case "p":
+			if m.pomo.running {
+				m.pomo.running = false
+				m.message = "Pomodoro stopped"
+			} else {
+				m.pomo.start(time.Now())
+				m.message = "Pomodoro started"
+			}
+		case "u":
+			m.stepHistory(undoChange, "Undid")
+		case "ctrl+r":
+			m.stepHistory(redoChange, "Redid")
+		case "]":
+			m.moveBoundary(m.selectedTimeline, 1)
+		case "[":
+			m.moveBoundary(m.selectedTimeline, -1)
+		case "}":
+			m.moveBoundary(m.selectedTimeline-1, 1)
+		case "{":
+			m.moveBoundary(m.selectedTimeline-1, -1)
 		case "enter":
-			if len(m.timelineBlocks) > 0 {
-				m.showTagDialog = true
-				m.tagInput = ""
-				m.selectedTag = 0
-				m.showingTagSuggestions = false
-				// Load available tags
-				m.availableTags = append([]string{}, m.store.Tags...)
+			if m.selectedTimeline < len(m.timelineBlocks) {
+				m.openEditor()
 			}
 		}
 	case tea.WindowSizeMsg:
@@ -382,14 +1579,29 @@ func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tickMsg:
 		// Only reload store data if we're not in tag dialog mode
 		// to avoid overwriting unsaved changes
-		if !m.showTagDialog {
+		if !m.showTagDialog && !m.showDateDialog && !m.showSplitDialog {
 			store, err := loadStore(m.filePath)
 			if err == nil {
 				m.store = store
 				m.buildTimelineBlocks()
+				m.refreshBalance()
+				if m.activeView == viewTags {
+					m.loadTagData()
+				}
 			}
 		}
-		return m, tickCmd()
+		now := time.Now()
+		cmds := []tea.Cmd{tickCmd()}
+		if title, body := m.pomo.advance(m.store, now, idleFor(now)); title != "" {
+			saveStore(m.filePath, m.store)
+			m.message = title + ": " + body
+			cmds = append(cmds, notifyCmd(m.store.Config, title, body))
+		}
+		if title, body := m.pomo.remind(m.store, now); title != "" {
+			m.message = title + ": " + body
+			cmds = append(cmds, notifyCmd(m.store.Config, title, body))
+		}
+		return m, tea.Batch(cmds...)
 	}
 	return m, nil
 }
@@ -402,28 +1614,109 @@ func (m dashboardModel) View() string {
 	now := time.Now()
 
 	// Header - full width
-	header := headerStyle.Width(m.width).Render(
-		fmt.Sprintf("🕐 Time Tracker Dashboard - %s", now.Format("Jan 2, 2006 15:04:05")),
+	headerText := fmt.Sprintf("🕐 Time Tracker Dashboard - %s", now.Format("Jan 2, 2006 15:04:05"))
+	if !m.day.IsZero() {
+		headerText += fmt.Sprintf(" • Viewing %s", m.day.Format("Mon Jan 2, 2006"))
+	}
+	if status := m.pomo.status(m.store.Config, now); status != "" {
+		headerText += " • " + status
+	}
+	header := headerStyle.Width(m.width).Render(headerText)
+
+	var content, help string
+	switch m.activeView {
+	case viewCalendar:
+		content = m.calendarView()
+		help = "←→ day • ↑↓ week • [ ] month • Enter open day in overview"
+	case viewTags:
+		content = m.tagExplorerView()
+		help = "↑↓ select • ←→ collapse/expand • Enter drill down • Backspace/Esc back • p change period • / filter (a AND b OR c)"
+	case viewTrends:
+		content = m.trendsView()
+		help = "Hour-of-day heatmap, focus sessions, weekly and monthly totals against your goals"
+	default:
+		content = m.overviewView()
+		help = "←→ change day • g go to date • t today • shift+↑↓/v select • space mark • a all untagged • s split • [] {} move edges • u undo • ctrl+r redo • +/- expand/collapse tags • p pomodoro"
+	}
+
+	footerText := "Press 'q' or Ctrl+C to quit • Tab/1-4 switch view • " + help + " • Updates every 30 seconds"
+	if m.message != "" {
+		footerText = m.message + " • " + footerText
+	}
+	footer := lipgloss.NewStyle().
+		Width(m.width).
+		Foreground(lipgloss.Color("#626262")).
+		Render(footerText)
+
+	// Use full terminal height, cutting off what doesn't fit
+	fullContent := lipgloss.JoinVertical(
+		lipgloss.Left,
+		header,
+		m.renderTabs(),
+		content,
 	)
+	room := m.height - lipgloss.Height(footer)
+	if lines := strings.Split(fullContent, "\n"); len(lines) > room {
+		fullContent = strings.Join(lines[:max(room, 1)], "\n")
+	}
+	fullContent = lipgloss.JoinVertical(lipgloss.Left, fullContent, footer)
+
+	contentHeight := lipgloss.Height(fullContent)
+	if contentHeight < m.height {
+		padding := strings.Repeat("\n", m.height-contentHeight-1)
+		fullContent += padding
+	}
+
+	return fullContent
+}
+
+func (m dashboardModel) renderTabs() string {
+	var tabs []string
+	for i, name := range viewNames {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if i == m.activeView {
+			tabs = append(tabs, selectedStyle.Render(label))
+		} else {
+			tabs = append(tabs, lipgloss.NewStyle().Foreground(lipgloss.Color("#A0A0A0")).Render(label))
+		}
+	}
+	return strings.Join(tabs, " ") + "\n"
+}
+
+// overviewView lays the overview boxes out in three columns, or in a single
+// column on narrow terminals.
+func (m dashboardModel) overviewView() string {
+	now := time.Now()
+	narrow := m.width < 120
 
 	// Today's stats
-	workMins, idleMins := todayTotals(m.store)
-	totalMins := workMins + idleMins
+	workMins, _ := todayTotals(m.store)
+
+	// Stats for the day shown in the timeline
+	viewStart, viewEnd := m.viewRange()
+	dayWorkMins, dayIdleMins := dayTotals(m.store, viewStart, viewEnd)
+	totalMins := dayWorkMins + dayIdleMins
 
 	var workPct, idlePct float64
 	if totalMins > 0 {
-		workPct = float64(workMins) / float64(totalMins) * 100
-		idlePct = float64(idleMins) / float64(totalMins) * 100
+		workPct = float64(dayWorkMins) / float64(totalMins) * 100
+		idlePct = float64(dayIdleMins) / float64(totalMins) * 100
 	}
 
 	// Calculate column widths - use full terminal width
 	leftColWidth := m.width/3 - 2
 	rightColWidth := (m.width*2)/3 - 4
 	rightSubColWidth := (rightColWidth - 4) / 2
+	if narrow {
+		leftColWidth, rightColWidth, rightSubColWidth = m.width-2, m.width-2, m.width-2
+	}
 
+	todayGoal := dailyGoal(m.store, now)
 	var progressText string
-	if isWorkDay(now, m.store.Config.WorkDays) {
-		progressText = fmt.Sprintf("Progress: %s", progressStyle.Render(formatPercentage(workMins, m.store.Config.DailyGoalMinutes)))
+	if todayGoal > 0 {
+		progressText = fmt.Sprintf("Progress: %s", progressStyle.Render(formatPercentage(workMins, todayGoal)))
+	} else if off := dayOff(m.store, now); off != nil {
+		progressText = fmt.Sprintf("Progress: Day off (%s)", off.label())
 	} else {
 		progressText = "Progress: Weekend/Non-workday"
 	}
@@ -438,8 +1731,8 @@ func (m dashboardModel) View() string {
 
 	// Progress Bar Box
 	goalPct := 0
-	if m.store.Config.DailyGoalMinutes > 0 {
-		goalPct = (workMins * 100) / m.store.Config.DailyGoalMinutes
+	if todayGoal > 0 {
+		goalPct = (workMins * 100) / todayGoal
 	}
 	progressBarWidth := leftColWidth - 10
 	if progressBarWidth < 20 {
@@ -450,27 +1743,36 @@ func (m dashboardModel) View() string {
 	progressBox := boxStyle.Width(leftColWidth).Render(fmt.Sprintf(
 		"🎯 DAILY GOAL PROGRESS\n\n%s",
 		func() string {
-			if isWorkDay(now, m.store.Config.WorkDays) {
-				return fmt.Sprintf("%s %d%%\n%s", progressBar, goalPct, progressStyle.Render(formatPercentage(workMins, m.store.Config.DailyGoalMinutes)))
+			if todayGoal > 0 {
+				return fmt.Sprintf("%s %d%%\n%s", progressBar, goalPct, progressStyle.Render(formatPercentage(workMins, todayGoal)))
 			}
 			return "No goal tracking on non-workdays"
 		}(),
 	))
 
-	longestFocus, contextSwitches := calculateFocusStats(m.store)
+	longestFocus, contextSwitches := calculateFocusStats(m.store, viewStart, viewEnd)
+	deepWork := summarizeFocus(m.store, viewStart, viewEnd).DeepWork
+
+	summaryTitle := "📊 TODAY'S SUMMARY"
+	if !m.day.IsZero() {
+		summaryTitle = "📊 SUMMARY " + strings.ToUpper(m.day.Format("Mon Jan 2"))
+	}
 
 	// Summary stats box
 	summaryBox := boxStyle.Width(leftColWidth).Render(fmt.Sprintf(
-		"📊 TODAY'S SUMMARY\n\n"+
+		"%s\n\n"+
 			"Working: %s %s (%.1f%%)\n"+
 			"Idle: %s %s (%.1f%%)\n"+
 			"Total: %s\n\n"+
 			"Longest Focus: %s\n"+
+			"Deep Work: %s\n"+
 			"Context Switches: %s",
-		workingStyle.Render("●"), humanDuration(workMins), workPct,
-		idleStyle.Render("●"), humanDuration(idleMins), idlePct,
+		summaryTitle,
+		workingStyle.Render("●"), humanDuration(dayWorkMins), workPct,
+		idleStyle.Render("●"), humanDuration(dayIdleMins), idlePct,
 		humanDuration(totalMins),
 		workingStyle.Render(humanDuration(longestFocus)),
+		workingStyle.Render(humanDuration(deepWork)),
 		progressStyle.Render(fmt.Sprintf("%d", contextSwitches)),
 	))
 
@@ -562,46 +1864,31 @@ func (m dashboardModel) View() string {
 		yearBar, yearPct)
 
 	periodBox := boxStyle.Width(rightSubColWidth).Render(periodContent)
+	balanceBox := boxStyle.Width(rightSubColWidth).Render(createBalanceBox(m.balance))
 
 	sevenDayBox := boxStyle.Width(rightSubColWidth).Render(create7DayWorkingHours(m.store, rightSubColWidth))
 
 	// Layout with full width
 	// Tag analytics box
 	// Tag analytics box
-	tagAnalyticsBox := boxStyle.Width(leftColWidth).Render(createTagAnalyticsBox(m.store, leftColWidth))
+	tagAnalyticsBox := boxStyle.Width(leftColWidth).Render(createTagAnalyticsBox(m.store, leftColWidth, m.tagDepth))
+	appAnalyticsBox := boxStyle.Width(leftColWidth).Render(createAppAnalyticsBox(m.store, leftColWidth))
 
 	// Reorganized layout - tag analytics on left side
-	leftColumn := lipgloss.JoinVertical(lipgloss.Left, workingHoursBox, progressBox, summaryBox, tagAnalyticsBox, liveBox)
+	leftColumn := lipgloss.JoinVertical(lipgloss.Left, workingHoursBox, progressBox, summaryBox, tagAnalyticsBox, appAnalyticsBox, liveBox)
 
 	// Right column with timeline at top, then other widgets below
 	rightTopColumn := timelineBox
 	rightBottomLeft := lipgloss.JoinVertical(lipgloss.Left, sevenDayBox, gridBox)
-	rightBottomRight := lipgloss.JoinVertical(lipgloss.Left, bestWorstBox, periodBox)
+	rightBottomRight := lipgloss.JoinVertical(lipgloss.Left, bestWorstBox, periodBox, balanceBox)
 	rightBottomRow := lipgloss.JoinHorizontal(lipgloss.Top, rightBottomLeft, rightBottomRight)
 	rightColumn := lipgloss.JoinVertical(lipgloss.Left, rightTopColumn, rightBottomRow)
 
-	content := lipgloss.JoinHorizontal(lipgloss.Top, leftColumn, rightColumn)
-
-	footer := lipgloss.NewStyle().
-		Width(m.width).
-		Foreground(lipgloss.Color("#626262")).
-		Render("Press 'q' or Ctrl+C to quit • Updates every 30 seconds")
-
-	// Use full terminal height
-	fullContent := lipgloss.JoinVertical(
-		lipgloss.Left,
-		header,
-		content,
-		footer,
-	)
-
-	contentHeight := lipgloss.Height(fullContent)
-	if contentHeight < m.height {
-		padding := strings.Repeat("\n", m.height-contentHeight-1)
-		fullContent += padding
+	if narrow {
+		return lipgloss.JoinVertical(lipgloss.Left, timelineBox, workingHoursBox, summaryBox, progressBox,
+			sevenDayBox, periodBox, balanceBox, tagAnalyticsBox, appAnalyticsBox, gridBox, bestWorstBox, liveBox)
 	}
-
-	return fullContent
+	return lipgloss.JoinHorizontal(lipgloss.Top, leftColumn, rightColumn)
 }
 
 func createProgressBar(percentage int, width int) string {
@@ -614,27 +1901,15 @@ func createProgressBar(percentage int, width int) string {
 }
 
 func (m *dashboardModel) createTimelineBox(width, maxHeight int) string {
-
-	now := time.Now()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	bins := fetchBins(m.store, start, now)
-
-	// Create full sequence from midnight to now
-	var seq []time.Time
-	for cur := floorToBin(start); cur.Before(floorToBin(now)); cur = cur.Add(binMinutes * time.Minute) {
-		seq = append(seq, cur)
+	title := "TODAY'S TIMELINE"
+	if !m.day.IsZero() {
+		title = "TIMELINE " + strings.ToUpper(m.day.Format("Mon Jan 2, 2006"))
 	}
-
-	// Initialize all bins as idle (0), then apply actual data
-	status := map[time.Time]int{}
-	for _, t := range seq {
-		status[t] = 0
-	}
-	for t, v := range bins {
-		status[t] = v
+	help := "↑↓ to navigate, Enter to edit"
+	if n := len(m.selectedBlocks()); m.anchor >= 0 || len(m.marked) > 0 {
+		help = fmt.Sprintf("%d selected, Enter to edit all, Esc to clear", n)
 	}
-
-	timeline := "📊 TODAY'S TIMELINE (↑↓ to navigate, Enter to tag)\n\n"
+	timeline := fmt.Sprintf("📊 %s (%s)\n\n", title, help)
 
 	// Calculate how many entries we can show based on available height
 	maxEntries := maxHeight - 8 // Reserve more space for dialog
@@ -647,6 +1922,9 @@ func (m *dashboardModel) createTimelineBox(width, maxHeight int) string {
 	if len(m.timelineBlocks) > maxEntries {
 		start_idx = len(m.timelineBlocks) - maxEntries
 	}
+	if m.selectedTimeline < start_idx {
+		start_idx = m.selectedTimeline
+	}
 
 	for i := start_idx; i < len(m.timelineBlocks); i++ {
 		block := m.timelineBlocks[i]
@@ -657,6 +1935,10 @@ func (m *dashboardModel) createTimelineBox(width, maxHeight int) string {
 			indicator = "🟢"
 			desc = "working"
 			style = workingStyle
+		} else if block.status == meetingStatus {
+			indicator = "📅"
+			desc = "meeting"
+			style = meetingStyle
 		} else {
 			indicator = "🔴"
 			desc = "idle"
@@ -668,14 +1950,31 @@ func (m *dashboardModel) createTimelineBox(width, maxHeight int) string {
 		// Build the line content
 		line := fmt.Sprintf("%s %s %s (%s)", indicator, timeRange, style.Render(desc), humanDuration(block.duration))
 
+		if block.override {
+			line += " ✎"
+		}
+
 		// Add tag if present
 		if block.tag != "" {
-			line += " " + tagStyle.Render(block.tag)
+			line += " " + renderTags(m.store, parseTags(block.tag))
+		}
+
+		// Add the note, cut to the space left on the line
+		if block.note != "" {
+			room := width - 8 - lipgloss.Width(line)
+			if note := []rune(block.note); room > 3 {
+				if len(note) > room {
+					note = append(note[:room-1], '…')
+				}
+				line += " " + noteStyle.Render(string(note))
+			}
 		}
 
-		// Highlight if selected
+		// Highlight the cursor and any selected blocks
 		if i == m.selectedTimeline {
 			line = selectedStyle.Render(line)
+		} else if m.isSelected(i) {
+			line = markedStyle.Render(line)
 		}
 
 		timeline += line + "\n"
@@ -687,881 +1986,5863 @@ func (m *dashboardModel) createTimelineBox(width, maxHeight int) string {
 		tagDialog := m.renderTagDialog()
 		content += "\n" + tagDialog
 	}
+	if m.showSplitDialog {
+		block := m.timelineBlocks[m.selectedTimeline]
+		content += "\n" + dialogStyle.Render(fmt.Sprintf(
+			"Split %s-%s at:\n\nTime: %s\n\nHH:MM, rounded down to %d minutes\nEnter to split, Esc to cancel",
+			block.start.Format("15:04"), block.end.Format("15:04"), m.splitInput.view(true), binMinutes))
+	}
+	if m.showDateDialog {
+		content += "\n" + dialogStyle.Render(fmt.Sprintf(
+			"Go to date:\n\nDate: %s\n\nYYYY-MM-DD, today, yesterday or -N days\nEnter to go, Esc to cancel", m.dateInput.view(true)))
+	}
 
 	return boxStyle.Width(width).Height(maxHeight).Render(content)
 }
 
-func loadStore(path string) (*Store, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return &Store{
-				Bins: map[string]int{},
-				Config: Config{
-					DailyGoalMinutes: 480,
-					WorkDays:         []int{1, 2, 3, 4, 5},
-				},
-			}, nil
-		}
-		return nil, err
-	}
-	defer f.Close()
-	var s Store
-	if err := json.NewDecoder(f).Decode(&s); err != nil {
-		return nil, err
-	}
-	if s.Bins == nil {
-		s.Bins = map[string]int{}
-	}
-
-	if s.Config.DailyGoalMinutes == 0 {
-		s.Config.DailyGoalMinutes = 480 // 8 hours
-	}
-	if len(s.Config.WorkDays) == 0 {
-		s.Config.WorkDays = []int{1, 2, 3, 4, 5} // Mon-Fri
-	}
+const (
+	viewOverview = iota
+	viewCalendar
+	viewTags
+	viewTrends
+)
 
-	return &s, nil
-}
+var (
+	viewNames  = []string{"Overview", "Calendar", "Tags", "Trends"}
+	tagPeriods = []int{7, 30, 90, 365} // days covered by the tag explorer
+	heatColors = []string{"#2D2D2D", "#0E4429", "#006D32", "#26A641", "#39D353"}
+)
 
-func saveStore(path string, s *Store) error {
-	tmp := path + ".tmp"
-	f, err := os.Create(tmp)
-	if err != nil {
-		return err
-	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(s); err != nil {
-		f.Close()
-		return err
-	}
-	if err := f.Close(); err != nil {
-		return err
+func (m *dashboardModel) switchView(v int) {
+	m.activeView = v
+	switch v {
+	case viewCalendar:
+		if m.calDay.IsZero() {
+			m.calDay, _ = m.viewRange()
+		}
+	case viewTags:
+		m.loadTagData()
 	}
-	return os.Rename(tmp, path)
 }
 
-func floorToBin(t time.Time) time.Time {
-	t = t.Truncate(time.Minute)
-	m := (t.Minute() / binMinutes) * binMinutes
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), m, 0, 0, t.Location())
+// handleViewKey handles the keys of the calendar, tag and trend views.
+func (m dashboardModel) handleViewKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.activeView {
+	case viewCalendar:
+		step := map[string]int{"left": -1, "h": -1, "right": 1, "l": 1, "up": -7, "k": -7, "down": 7, "j": 7}
+		switch msg.String() {
+		case "[":
+			m.calDay = m.calDay.AddDate(0, -1, 0)
+		case "]":
+			m.calDay = m.calDay.AddDate(0, 1, 0)
+		case "t":
+			m.calDay = time.Now()
+		case "enter":
+			m.setDay(m.calDay)
+			m.activeView = viewOverview
+		case "esc":
+			m.activeView = viewOverview
+		default:
+			if n, ok := step[msg.String()]; ok {
+				m.calDay = m.calDay.AddDate(0, 0, n)
+			}
+		}
+		if now := time.Now(); m.calDay.After(now) {
+			m.calDay = now
+		}
+	case viewTags:
+		rows := len(m.tagExplorerRows())
+		switch msg.String() {
+		case "up", "k":
+			if m.tagCursor[m.tagLevel] > 0 {
+				m.tagCursor[m.tagLevel]--
+			}
+		case "down", "j":
+			if m.tagCursor[m.tagLevel] < rows-1 {
+				m.tagCursor[m.tagLevel]++
+			}
+		case "enter":
+			if rows == 0 {
+				break
+			}
+			row := m.tagExplorerRows()[m.tagCursor[m.tagLevel]]
+			switch m.tagLevel {
+			case 0:
+				m.tagSel = row.key
+			case 1:
+				m.tagDay = row.key
+			case 2:
+				// open the block's day in the overview timeline
+				day, _ := time.ParseInLocation("2006-01-02", m.tagDay, time.Local)
+				m.setDay(day)
+				m.activeView = viewOverview
+				return m, nil
+			}
+			m.tagLevel++
+			m.tagCursor[m.tagLevel] = 0
+		case "right", "l", "left", "h":
+			if m.tagLevel > 0 || rows == 0 {
+				break
+			}
+			path := m.tagExplorerRows()[m.tagCursor[0]].key
+			if m.tagCollapsed == nil {
+				m.tagCollapsed = map[string]bool{}
+			}
+			if msg.String() == "right" || msg.String() == "l" {
+				delete(m.tagCollapsed, path)
+			} else if !m.tagCollapsed[path] && strings.Contains(m.tagExplorerRows()[m.tagCursor[0]].label, "▾") {
+				m.tagCollapsed[path] = true
+			} else if i := strings.LastIndex(path, "/"); i > 0 {
+				// on a leaf or collapsed node, fold the parent and move to it
+				m.tagCollapsed[path[:i]] = true
+				for j, r := range m.tagExplorerRows() {
+					if r.key == path[:i] {
+						m.tagCursor[0] = j
+					}
+				}
+			}
+		case "backspace", "esc":
+			if m.tagLevel > 0 {
+				m.tagLevel--
+			} else {
+				m.activeView = viewOverview
+			}
+		case "p":
+			m.tagPeriod = (m.tagPeriod + 1) % len(tagPeriods)
+			m.tagLevel = 0
+			m.tagCursor = [3]int{}
+			m.loadTagData()
+		case "/":
+			m.editingFilter = true
+			m.filterInput = newTextInput(m.tagFilter)
+		}
+	case viewTrends:
+		if msg.String() == "esc" {
+			m.activeView = viewOverview
+		}
+	}
+	return m, nil
 }
 
-func nextBinStart(t time.Time) time.Time { return floorToBin(t).Add(binMinutes * time.Minute) }
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
 
-func humanDuration(mins int) string {
-	h := mins / 60
-	m := mins % 60
+// startOfWeek returns the Monday starting t's week.
+func startOfWeek(t time.Time) time.Time {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return startOfDay(t).AddDate(0, 0, -(weekday - 1))
+}
+
+// heatLevel grades a day's work from 0 (none) to 4, against the goal when there is one.
+func heatLevel(workMins, goal int) int {
+	if workMins <= 0 {
+		return 0
+	}
+	if goal <= 0 {
+		goal = 480
+	}
+	pct := workMins * 100 / goal
 	switch {
-	case h > 0 && m > 0:
-		return fmt.Sprintf("%d hr %d mins", h, m)
-	case h > 0:
-		if h == 1 {
-			return "1 hr"
-		}
-		return fmt.Sprintf("%d hrs", h)
-	case m == 1:
-		return "1 min"
-	default:
-		return fmt.Sprintf("%d mins", m)
+	case pct < 25:
+		return 1
+	case pct < 60:
+		return 2
+	case pct < 100:
+		return 3
 	}
+	return 4
 }
 
-// macOS idle seconds via `ioreg -c IOHIDSystem`, parsing HIDIdleTime (nanoseconds since last input)
-var hidIdleRe = regexp.MustCompile(`HIDIdleTime"\s*=\s*([0-9]+)`)
+func (m dashboardModel) calendarView() string {
+	now := time.Now()
+	first := time.Date(m.calDay.Year(), m.calDay.Month(), 1, 0, 0, 0, 0, now.Location())
+	last := first.AddDate(0, 1, 0)
+	worked := workedByDay(m.store, first, last)
 
-func getIdleSecondsMac() (float64, error) {
-	cmd := exec.Command("/usr/sbin/ioreg", "-c", "IOHIDSystem")
-	out, err := cmd.Output()
-	if err != nil {
-		return 0, err
+	cellWidth := min(max((m.width-10)/7, 6), 16)
+	cell := lipgloss.NewStyle().Width(cellWidth).Align(lipgloss.Center)
+
+	content := fmt.Sprintf("📅 %s\n\n", strings.ToUpper(first.Format("January 2006")))
+	var head []string
+	for _, d := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		head = append(head, cell.Render(d))
 	}
-	scanner := bufio.NewScanner(strings.NewReader(string(out)))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "HIDIdleTime") {
-			m := hidIdleRe.FindStringSubmatch(line)
-			if len(m) == 2 {
-				ns, _ := strconv.ParseFloat(m[1], 64)
-				return ns / 1_000_000_000.0, nil
+	content += strings.Join(head, " ") + "\n"
+
+	spacer := m.height >= 30
+	total := 0
+	for week := startOfWeek(first); week.Before(last); week = week.AddDate(0, 0, 7) {
+		var cells []string
+		for d := week; d.Before(week.AddDate(0, 0, 7)); d = d.AddDate(0, 0, 1) {
+			if d.Month() != first.Month() {
+				cells = append(cells, cell.Render(""))
+				continue
 			}
+			mins := worked[d.Format("2006-01-02")]
+			total += mins
+			label := fmt.Sprintf("%d", d.Day())
+			if mins > 0 && cellWidth >= 8 {
+				label += fmt.Sprintf(" %.1fh", float64(mins)/60)
+			}
+			style := cell.Background(lipgloss.Color(heatColors[heatLevel(mins, dailyGoal(m.store, d))]))
+			switch {
+			case d.Equal(startOfDay(m.calDay)):
+				style = style.Background(lipgloss.Color("#7D56F4")).Bold(true)
+			case d.After(now):
+				style = style.Background(lipgloss.Color("")).Foreground(lipgloss.Color("#626262"))
+			case dayOff(m.store, d) != nil && mins == 0:
+				style = style.Background(lipgloss.Color("#1F4E79"))
+			}
+			cells = append(cells, style.Render(label))
+		}
+		content += strings.Join(cells, " ") + "\n"
+		if spacer {
+			content += "\n"
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return 0, err
-	}
-	return 0, fmt.Errorf("HIDIdleTime not found")
-}
 
-func lastActivity(now time.Time) (time.Time, error) {
-	idle, err := getIdleSecondsMac()
-	if err != nil {
-		return time.Time{}, err
+	content += "\nLess "
+	for _, c := range heatColors {
+		content += lipgloss.NewStyle().Background(lipgloss.Color(c)).Render("  ") + " "
 	}
-	return now.Add(-time.Duration(idle * float64(time.Second))), nil
+	content += "More (against the daily goal)  " + lipgloss.NewStyle().Background(lipgloss.Color("#1F4E79")).Render("  ") + " Day off\n\n"
+
+	content += fmt.Sprintf("Month: %s / %s\n\n", workingStyle.Render(humanDuration(total)),
+		progressStyle.Render(humanDuration(periodGoal(m.store, first, last))))
+
+	// Details of the selected day
+	day := startOfDay(m.calDay)
+	mins := worked[day.Format("2006-01-02")]
+	content += fmt.Sprintf("%s: %s", day.Format("Mon Jan 2"), workingStyle.Render(humanDuration(mins)))
+	if goal := dailyGoal(m.store, day); goal > 0 {
+		content += " of " + progressStyle.Render(humanDuration(goal))
+	} else if off := dayOff(m.store, day); off != nil {
+		content += fmt.Sprintf(" (day off: %s)", off.label())
+	}
+	tags := tagMinutes(m.store, day, day.AddDate(0, 0, 1))
+	for i, tag := range sortedByMinutes(tags) {
+		if i == 5 {
+			break
+		}
+		content += fmt.Sprintf("\n  %s %s", tagStyleFor(m.store, tag).Render(tag), humanDuration(tags[tag]))
+	}
+
+	return boxStyle.Width(m.width - 2).Render(content)
 }
 
-func upsertBin(s *Store, binStart time.Time, working bool) {
-	k := strconv.FormatInt(binStart.Unix(), 10)
-	cur := s.Bins[k]
-	if working && cur == 0 {
-		s.Bins[k] = 1
-	} else if cur == 0 && !working {
-		if _, ok := s.Bins[k]; !ok {
-			s.Bins[k] = 0
+// tagMinutes sums the working minutes per tag between start and end.
+func tagMinutes(s *Store, start, end time.Time) map[string]int {
+	res := map[string]float64{}
+	ix := indexBins(s, start, end)
+	for t, v := range fetchBins(s, start, end) {
+		if v != 1 {
+			continue
+		}
+		for tag, mins := range tagShares(s.Config, ix.binTags(t)) {
+			res[tag] += mins
 		}
 	}
+	return roundMinutes(res)
 }
 
-func fetchBins(s *Store, start, end time.Time) map[time.Time]int {
-	res := make(map[time.Time]int)
-
-	for k, v := range s.Bins {
-		ts, err := strconv.ParseInt(k, 10, 64)
-		if err != nil {
+// loadTagData collects minutes per tag and day for the tag explorer period,
+// counting only blocks that match the explorer's filter.
+func (m *dashboardModel) loadTagData() {
+	end := time.Now()
+	start := startOfDay(end).AddDate(0, 0, 1-tagPeriods[m.tagPeriod])
+	filter := parseTagQuery(m.tagFilter)
+	shares := map[string]map[string]float64{}
+	ix := indexBins(m.store, start, end)
+	for t, v := range fetchBins(m.store, start, end) {
+		tags := ix.binTags(t)
+		if v != 1 || !filter.match(tags) {
 			continue
 		}
-		t := time.Unix(ts, 0)
-		if !t.Before(start) && t.Before(end) {
-			res[t] = v
+		for tag, mins := range tagShares(m.store.Config, tags) {
+			if shares[tag] == nil {
+				shares[tag] = map[string]float64{}
+			}
+			shares[tag][t.Format("2006-01-02")] += mins
 		}
 	}
+	m.tagData = map[string]map[string]int{}
+	for tag, days := range shares {
+		m.tagData[tag] = roundMinutes(days)
+	}
+}
 
-	for _, r := range s.Ranges {
-		rStart := time.Unix(r.Start, 0)
-		rEnd := time.Unix(r.End, 0)
+type explorerRow struct {
+	key   string
+	label string
+	mins  int
+	extra string
+}
 
-		if rEnd.Before(start) || !rStart.Before(end) {
-			continue
+// tagExplorerRows lists what the tag explorer shows at its current level:
+// tags, the days of the chosen tag, or that tag's blocks on the chosen day.
+func (m dashboardModel) tagExplorerRows() []explorerRow {
+	var rows []explorerRow
+	switch m.tagLevel {
+	case 0:
+		totals := map[string]int{}
+		for tag, days := range m.tagData {
+			for _, mins := range days {
+				totals[tag] += mins
+			}
+		}
+		rolled := rollupTags(totals)
+		collapsed := func(n tagNode) bool { return m.tagCollapsed[n.path] }
+		for _, n := range visibleTagNodes(tagTree(rolled), collapsed) {
+			days := len(m.tagDays(n.path))
+			rows = append(rows, explorerRow{key: n.path, label: n.label(collapsed(n)), mins: rolled[n.path], extra: fmt.Sprintf("%d day(s)", days)})
+		}
+	case 1:
+		byDay := m.tagDays(m.tagSel)
+		var days []string
+		for day := range byDay {
+			days = append(days, day)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(days)))
+		for _, day := range days {
+			t, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+			rows = append(rows, explorerRow{key: day, label: t.Format("Mon Jan 2"), mins: byDay[day]})
+		}
+	case 2:
+		start, _ := time.ParseInLocation("2006-01-02", m.tagDay, time.Local)
+		bins := fetchBins(m.store, start, start.AddDate(0, 0, 1))
+		ix := indexBins(m.store, start, start.AddDate(0, 0, 1))
+		filter, sel := parseTagQuery(m.tagFilter), tagQuery{{m.tagSel}}
+		credit := map[time.Time]float64{}
+		var times []time.Time
+		for t, v := range bins {
+			tags := ix.binTags(t)
+			if v != 1 || !filter.match(tags) {
+				continue
+			}
+			if credit[t] = sel.credit(m.store.Config, tags); credit[t] > 0 {
+				times = append(times, t)
+			}
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		// blocks show their other tags, subtags relative to the selection, and their note
+		describe := func(t time.Time) string {
+			var parts []string
+			for _, tag := range ix.binTags(t) {
+				if tag != m.tagSel {
+					parts = append(parts, strings.TrimPrefix(tag, m.tagSel+"/"))
+				}
+			}
+			desc := joinTags(parts)
+			if idx := ix.tagRangeAt(t); idx >= 0 && m.store.Ranges[idx].Note != "" {
+				desc = strings.TrimSpace(desc + " " + m.store.Ranges[idx].Note)
+			}
+			return desc
+		}
+		for i := 0; i < len(times); {
+			mins := credit[times[i]]
+			j := i + 1
+			for j < len(times) && times[j].Sub(times[j-1]) == binMinutes*time.Minute && describe(times[j]) == describe(times[i]) {
+				mins += credit[times[j]]
+				j++
+			}
+			end := times[j-1].Add(binMinutes * time.Minute)
+			rows = append(rows, explorerRow{
+				key:   times[i].Format("15:04"),
+				label: times[i].Format("15:04") + "-" + end.Format("15:04"),
+				mins:  int(mins + 0.5),
+				extra: describe(times[i]),
+			})
+			i = j
 		}
+	}
+	return rows
+}
 
-		for cur := floorToBin(rStart); cur.Before(rEnd) && cur.Before(end); cur = cur.Add(binMinutes * time.Minute) {
-			if !cur.Before(start) {
-				res[cur] = r.Status
+// tagDays sums the explorer's minutes per day for tag and its descendants.
+func (m dashboardModel) tagDays(tag string) map[string]int {
+	out := map[string]int{}
+	for t, days := range m.tagData {
+		if tagMatches(t, tag) {
+			for day, mins := range days {
+				out[day] += mins
 			}
 		}
 	}
+	return out
+}
 
-	return res
+func (m dashboardModel) tagExplorerView() string {
+	title := fmt.Sprintf("🏷️  TAG EXPLORER • last %d days", tagPeriods[m.tagPeriod])
+	switch m.tagLevel {
+	case 1:
+		title += " › " + m.tagSel
+	case 2:
+		title += " › " + m.tagSel + " › " + m.tagDay
+	}
+	content := title + "\n"
+	if m.editingFilter {
+		content += "Filter: " + m.filterInput.view(true) + "\n"
+	} else if m.tagFilter != "" {
+		content += "Filter: " + m.tagFilter + "\n"
+	}
+	content += "\n"
+
+	rows := m.tagExplorerRows()
+	if len(rows) == 0 {
+		content += "No working time recorded in this period"
+		return boxStyle.Width(m.width - 2).Render(content)
+	}
+
+	most := 0
+	for _, r := range rows {
+		most = max(most, r.mins)
+	}
+	barWidth := max(m.width/3, 10)
+	visible := max(m.height-14, 5)
+	cursor := m.tagCursor[m.tagLevel]
+	first := 0
+	if cursor >= visible {
+		first = cursor - visible + 1
+	}
+	for i := first; i < len(rows) && i < first+visible; i++ {
+		r := rows[i]
+		bar := createProgressBar(r.mins*100/max(most, 1), barWidth)
+		line := fmt.Sprintf("%-28s %s %-14s %s", r.label, bar, humanDuration(r.mins), r.extra)
+		if i == cursor {
+			line = selectedStyle.Render(line)
+		}
+		content += line + "\n"
+	}
+	if len(rows) > visible {
+		content += fmt.Sprintf("\n%d of %d", cursor+1, len(rows))
+	}
+	return boxStyle.Width(m.width - 2).Render(content)
 }
 
-func reportToday(s *Store) {
+func (m dashboardModel) trendsView() string {
 	now := time.Now()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	end := now
-	bins := fetchBins(s, start, end)
+	narrow := m.width < 120
+	weeks := min(max(m.height-26, 4), 26)
+	months := 12
+	if narrow {
+		// both lists are stacked, so share the height
+		weeks = min(weeks, max((m.height-24)/2, 8))
+		months = 6
+	}
+	from := startOfWeek(now).AddDate(0, 0, -7*(weeks-1))
+	if monthStart := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location()); monthStart.Before(from) {
+		from = monthStart
+	}
+	worked := workedByDay(m.store, from, now)
+	sum := func(start, end time.Time) int {
+		total := 0
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			total += worked[d.Format("2006-01-02")]
+		}
+		return total
+	}
 
-	// build full sequence of bins and merge contiguous
-	var seq []time.Time
-	for cur := floorToBin(start); cur.Before(floorToBin(end)); cur = cur.Add(binMinutes * time.Minute) {
-		seq = append(seq, cur)
+	colWidth := m.width/2 - 2
+	if narrow {
+		colWidth = m.width - 2
 	}
-	status := map[time.Time]int{}
-	for _, t := range seq {
-		status[t] = 0
+	barWidth := max(colWidth-40, 10)
+
+	weekly := "📈 WEEKLY TOTALS\n\n"
+	var totals []int
+	for i := 0; i < weeks; i++ {
+		start := startOfWeek(now).AddDate(0, 0, -7*(weeks-1-i))
+		end := start.AddDate(0, 0, 7)
+		mins, goal := sum(start, end), periodGoal(m.store, start, end)
+		totals = append(totals, mins)
+		pct := 0
+		if goal > 0 {
+			pct = mins * 100 / goal
+		}
+		weekly += fmt.Sprintf("%s  %s %s / %s\n", start.Format("Jan 02"), createProgressBar(pct, barWidth),
+			workingStyle.Render(fmt.Sprintf("%5.1fh", float64(mins)/60)), fmt.Sprintf("%.0fh", float64(goal)/60))
 	}
-	for t, v := range bins {
-		status[t] = v
+	if len(totals) >= 8 {
+		recent, earlier := 0, 0
+		for i := 0; i < 4; i++ {
+			recent += totals[len(totals)-1-i]
+			earlier += totals[len(totals)-5-i]
+		}
+		arrow := "→"
+		if recent > earlier*11/10 {
+			arrow = workingStyle.Render("↑")
+		} else if recent < earlier*9/10 {
+			arrow = idleStyle.Render("↓")
+		}
+		weekly += fmt.Sprintf("\nLast 4 weeks: %s avg/week %s (before: %s)",
+			humanDuration(recent/4), arrow, humanDuration(earlier/4))
 	}
 
-	fmt.Println(now.Format("Date : Jan 2, 2006 , Monday"))
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Printf("%-15s | %-12s | %s\n", "Time Range", "Duration", "Description")
-	fmt.Println(strings.Repeat("-", 50))
+	monthly := "🗓️  MONTHLY TOTALS\n\n"
+	for i := months - 1; i >= 0; i-- {
+		start := time.Date(now.Year(), now.Month()-time.Month(i), 1, 0, 0, 0, 0, now.Location())
+		end := start.AddDate(0, 1, 0)
+		mins, goal := sum(start, end), periodGoal(m.store, start, end)
+		pct := 0
+		if goal > 0 {
+			pct = mins * 100 / goal
+		}
+		monthly += fmt.Sprintf("%s  %s %s / %s\n", start.Format("Jan 2006"), createProgressBar(pct, barWidth),
+			workingStyle.Render(fmt.Sprintf("%5.1fh", float64(mins)/60)), fmt.Sprintf("%.0fh", float64(goal)/60))
+	}
 
-	totalWork := 0
-	for i := 0; i < len(seq); {
-		startBin := seq[i]
-		st := status[startBin]
-		j := i
-		for j < len(seq) && status[seq[j]] == st {
-			j++
+	days := heatmapDays(m.store.Config)
+	heatStart := startOfDay(now).AddDate(0, 0, 1-days)
+	heatmap := fmt.Sprintf("🕒 WHEN YOU WORK (last %d days)\n\n", days) + renderHourHeatmap(hourHeatmap(m.store, heatStart, now))
+
+	_, focusWeeks := weeklyFocus(m.store, min(weeks, 12))
+	focus := "🎯 FOCUS SESSIONS (per week)\n\n" + focusTrends(focusWeeks)
+
+	weeklyBox := boxStyle.Width(colWidth).Render(strings.TrimRight(weekly, "\n"))
+	focusBox := boxStyle.Width(colWidth).Render(focus)
+	monthlyBox := boxStyle.Width(colWidth).Render(strings.TrimRight(monthly, "\n"))
+	heatmapBox := boxStyle.Width(colWidth).Render(heatmap)
+	if narrow {
+		return lipgloss.JoinVertical(lipgloss.Left, heatmapBox, focusBox, weeklyBox, monthlyBox)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.JoinVertical(lipgloss.Left, weeklyBox, focusBox),
+		lipgloss.JoinVertical(lipgloss.Left, heatmapBox, monthlyBox))
+}
+
+func loadStore(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Store{
+				Bins: map[string]int{},
+				Config: Config{
+					DailyGoalMinutes: 480,
+					WorkDays:         []int{1, 2, 3, 4, 5},
+				},
+			}, nil
 		}
-		endBin := seq[j-1].Add(binMinutes * time.Minute)
-		mins := int(endBin.Sub(startBin).Minutes())
-		desc := "idle"
-		if st == 1 {
-			desc = "working"
-			totalWork += mins
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(storeMagic)) {
+		if data, err = openStore(path, data); err != nil {
+			return nil, err
 		}
-		fmt.Printf("%s-%-7s | %-12s | %s\n", startBin.Format("15:04"), endBin.Format("15:04"), humanDuration(mins), desc)
-		i = j
 	}
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Printf("Total working today : %s\n", humanDuration(totalWork))
-	if isWorkDay(now, s.Config.WorkDays) {
-		fmt.Printf("Daily goal progress: %s\n", formatPercentage(totalWork, s.Config.DailyGoalMinutes))
+	var s Store
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Bins == nil {
+		s.Bins = map[string]int{}
 	}
-}
 
-func create7DayWorkingHours(s *Store, width int) string {
-	now := time.Now()
-	content := "📊 LAST 7 DAYS\n\n"
+	if s.Config.DailyGoalMinutes == 0 {
+		s.Config.DailyGoalMinutes = 480 // 8 hours
+	}
+	if len(s.Config.WorkDays) == 0 {
+		s.Config.WorkDays = []int{1, 2, 3, 4, 5} // Mon-Fri
+	}
 
-	totalWeekHours := 0
+	// windowAt searches the spans by time; merged stores may list them out of order.
+	sort.SliceStable(s.Windows, func(i, j int) bool { return s.Windows[i].Start < s.Windows[j].Start })
 
-	for dayIndex := 0; dayIndex < 7; dayIndex++ {
-		targetDay := now.AddDate(0, 0, -(6 - dayIndex)) // Start from 6 days ago to today
+	// Older stores had a single tag per range, also in the change history.
+	migrateTags := func(ranges []Range) {
+		for i := range ranges {
+			if ranges[i].Tag != "" {
+				ranges[i].Tags = parseTags(ranges[i].Tag)
+				ranges[i].Tag = ""
+			}
+		}
+	}
+	migrateTags(s.Ranges)
+	for _, c := range s.History {
+		migrateTags(c.Removed)
+		migrateTags(c.Added)
+	}
 
-		// Get working minutes for this day
-		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		bins := fetchBins(s, dayStart, dayEnd)
+	// Older stores kept tags on status ranges; move them to tag-only ranges
+	// so a block can be retagged without touching the rest of the range.
+	for i := range s.Ranges {
+		if r := s.Ranges[i]; !r.TagOnly && !r.Override && (len(r.Tags) > 0 || r.Note != "") {
+			r.TagOnly = true
+			s.Ranges = append(s.Ranges, r)
+			s.Ranges[i].Tags, s.Ranges[i].Note, s.Ranges[i].Source = nil, "", ""
+		}
+	}
 
-		workMins := 0
-		for _, v := range bins {
-			if v == 1 {
-				workMins += binMinutes
+	// Splits of untagged blocks used to be empty manual tag ranges, which kept
+	// auto-tagging off the span; they are split markers now.
+	migrateSplits := func(ranges []Range) {
+		for i, r := range ranges {
+			if r.TagOnly && len(r.Tags) == 0 && r.Note == "" && r.Source == "" {
+				ranges[i] = Range{Start: r.End, End: r.End, Split: true}
 			}
 		}
-		totalWeekHours += workMins
-
-		// Format the day
-		dayName := targetDay.Format("Mon")
-		dateStr := targetDay.Format("Jan 2")
+	}
+	migrateSplits(s.Ranges)
+	for _, c := range s.History {
+		migrateSplits(c.Removed)
+		migrateSplits(c.Added)
+	}
 
-		// Color coding based on work hours and if it's a work day
-		var dayStyle lipgloss.Style
-		var indicator string
+	return &s, nil
+}
 
-		isWork := isWorkDay(targetDay, s.Config.WorkDays)
+// saveStore keeps the file's format: an encrypted store is written back
+// encrypted with the same key.
+func saveStore(path string, s *Store) error {
+	header, err := readStoreHeader(path)
+	if err != nil {
+		return err
+	}
+	return writeStore(path, s, header)
+}
 
-		if workMins == 0 {
-			dayStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
-			indicator = "⚫"
-		} else if isWork && workMins >= s.Config.DailyGoalMinutes {
-			dayStyle = workingStyle
-			indicator = "✅"
-		} else if workMins > 0 {
-			dayStyle = progressStyle
-			indicator = "🟡"
+// writeStore writes s as plain JSON, or encrypted when header is set.
+func writeStore(path string, s *Store, header []byte) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if header != nil {
+		key, _, err := storeKey(path, header, true)
+		if err != nil {
+			return err
+		}
+		if data, err = sealStore(header, key, data); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// An encrypted store starts with storeMagic, the key kind ('p' for a
+// passphrase, 'k' for a key file), the scrypt salt and log2 of its cost N,
+// followed by the nonce and the AES-256-GCM sealed JSON.
+const (
+	storeMagic     = "TTCRYPT1"
+	storeHeaderLen = len(storeMagic) + 1 + 16 + 1
+	passphraseCost = 15 // 32 MiB and about 100ms
+	keyFileCost    = 10 // key files hold 32 random bytes already
+	maxStoreCost   = 20 // the header is read before it can be verified
+)
+
+var (
+	storeKeysMu sync.Mutex
+	storeKeys   = map[string][]byte{} // header -> derived key
+)
+
+func deriveStoreKey(secret, salt []byte, cost int) ([]byte, error) {
+	return scrypt.Key(secret, salt, 1<<cost, 8, 1, 32)
+}
+
+// readStoreHeader returns the encryption header of the store at path, or nil
+// when the file is plain JSON or doesn't exist.
+func readStoreHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header := make([]byte, storeHeaderLen)
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.HasPrefix(header, []byte(storeMagic)) {
+		return nil, nil
+	}
+	return header, nil
+}
+
+// storeKey derives the key for an encrypted store, asking for the secret only
+// once per process. fromKeyring reports whether the secret came from the OS
+// keyring; with keyring false it is not asked.
+func storeKey(path string, header []byte, keyring bool) (key []byte, fromKeyring bool, err error) {
+	storeKeysMu.Lock()
+	defer storeKeysMu.Unlock()
+	if key, ok := storeKeys[string(header)]; ok {
+		return key, false, nil
+	}
+	n := len(storeMagic)
+	kind, salt, cost := header[n], header[n+1:n+17], int(header[n+17])
+	if kind != 'p' && kind != 'k' || cost < 1 || cost > maxStoreCost {
+		return nil, false, fmt.Errorf("%s: unsupported encryption header", path)
+	}
+	secret, fromKeyring, err := storeSecret(path, kind, keyring)
+	if err != nil {
+		return nil, false, err
+	}
+	if key, err = deriveStoreKey(secret, salt, cost); err != nil {
+		return nil, false, err
+	}
+	storeKeys[string(header)] = key
+	return key, fromKeyring, nil
+}
+
+func forgetStoreKey(header []byte) {
+	storeKeysMu.Lock()
+	delete(storeKeys, string(header))
+	storeKeysMu.Unlock()
+}
+
+// newStoreKey creates a header with a fresh salt for secret and remembers its key.
+func newStoreKey(kind byte, secret []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cost := passphraseCost
+	if kind == 'k' {
+		cost = keyFileCost
+	}
+	key, err := deriveStoreKey(secret, salt, cost)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append([]byte(storeMagic), kind), salt...)
+	header = append(header, byte(cost))
+	storeKeysMu.Lock()
+	storeKeys[string(header)] = key
+	storeKeysMu.Unlock()
+	return header, nil
+}
+
+func storeAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealStore(header, key, data []byte) ([]byte, error) {
+	aead, err := storeAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(slices.Clone(header), nonce...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+// openStore decrypts an encrypted store. When a passphrase from the keyring
+// doesn't fit, e.g. after a rekey elsewhere, it asks for the passphrase instead.
+func openStore(path string, data []byte) ([]byte, error) {
+	if len(data) < storeHeaderLen {
+		return nil, fmt.Errorf("%s: truncated encrypted store", path)
+	}
+	header, rest := data[:storeHeaderLen], data[storeHeaderLen:]
+	for _, keyring := range []bool{true, false} {
+		key, fromKeyring, err := storeKey(path, header, keyring)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := storeAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < aead.NonceSize() {
+			return nil, fmt.Errorf("%s: truncated encrypted store", path)
+		}
+		plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+		if err == nil {
+			return plain, nil
+		}
+		forgetStoreKey(header)
+		if !fromKeyring {
+			break
+		}
+	}
+	return nil, fmt.Errorf("%s: wrong passphrase or key file", path)
+}
+
+// storeSecret finds the passphrase or key file for an encrypted store. The
+// environment and the OS keyring come first so the tracker can run under a
+// login agent; a terminal prompt is the last resort.
+func storeSecret(path string, kind byte, keyring bool) (secret []byte, fromKeyring bool, err error) {
+	if kind == 'k' {
+		keyFile := os.Getenv("TIMETRACK_KEYFILE")
+		if keyFile == "" {
+			return nil, false, fmt.Errorf("%s is encrypted with a key file, set TIMETRACK_KEYFILE", path)
+		}
+		secret, err = os.ReadFile(keyFile)
+		return secret, false, err
+	}
+	if p := os.Getenv("TIMETRACK_PASSPHRASE"); p != "" {
+		return []byte(p), false, nil
+	}
+	if keyring {
+		if p, err := keyringGet(path); err == nil && p != "" {
+			return []byte(p), true, nil
+		}
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return nil, false, fmt.Errorf("%s is encrypted, set TIMETRACK_PASSPHRASE or save the passphrase in the keyring", path)
+	}
+	secret, err = readPassphrase(fmt.Sprintf("Passphrase for %s: ", path))
+	return secret, false, err
+}
+
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	return p, err
+}
+
+// newPassphrase takes a new passphrase from env, or asks for it twice.
+func newPassphrase(env string) ([]byte, error) {
+	if p := os.Getenv(env); p != "" {
+		return []byte(p), nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return nil, fmt.Errorf("set %s or run in a terminal", env)
+	}
+	p, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(p) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	again, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, again) {
+		return nil, errors.New("passphrases don't match")
+	}
+	return p, nil
+}
+
+// keyringAccount names a store in the OS keyring by its absolute path.
+func keyringAccount(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func keyringGet(path string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", "timetrackcli", "-a", keyringAccount(path), "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", "timetrackcli", "store", keyringAccount(path))
+	default:
+		return "", fmt.Errorf("no keyring support on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// keyringSet saves the passphrase for path; it goes through stdin, never argv.
+func keyringSet(path string, passphrase []byte) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// -w without a value prompts for the password, twice
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", "timetrackcli", "-a", keyringAccount(path), "-w")
+		line := append(slices.Clone(passphrase), '\n')
+		cmd.Stdin = bytes.NewReader(append(slices.Clone(line), line...))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label=timetrackcli store", "service", "timetrackcli", "store", keyringAccount(path))
+		cmd.Stdin = bytes.NewReader(passphrase)
+	default:
+		return fmt.Errorf("no keyring support on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keyring: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keyringDelete removes the saved passphrase for path, if there is one.
+func keyringDelete(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", "timetrackcli", "-a", keyringAccount(path))
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", "timetrackcli", "store", keyringAccount(path))
+	default:
+		return fmt.Errorf("no keyring support on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
+
+func floorToBin(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	m := (t.Minute() / binMinutes) * binMinutes
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), m, 0, 0, t.Location())
+}
+
+func nextBinStart(t time.Time) time.Time { return floorToBin(t).Add(binMinutes * time.Minute) }
+
+func humanDuration(mins int) string {
+	h := mins / 60
+	m := mins % 60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%d hr %d mins", h, m)
+	case h > 0:
+		if h == 1 {
+			return "1 hr"
+		}
+		return fmt.Sprintf("%d hrs", h)
+	case m == 1:
+		return "1 min"
+	default:
+		return fmt.Sprintf("%d mins", m)
+	}
+}
+
+// macOS idle seconds via `ioreg -c IOHIDSystem`, parsing HIDIdleTime (nanoseconds since last input)
+var hidIdleRe = regexp.MustCompile(`HIDIdleTime"\s*=\s*([0-9]+)`)
+
+func getIdleSecondsMac() (float64, error) {
+	cmd := exec.Command("/usr/sbin/ioreg", "-c", "IOHIDSystem")
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "HIDIdleTime") {
+			m := hidIdleRe.FindStringSubmatch(line)
+			if len(m) == 2 {
+				ns, _ := strconv.ParseFloat(m[1], 64)
+				return ns / 1_000_000_000.0, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("HIDIdleTime not found")
+}
+
+func lastActivity(now time.Time) (time.Time, error) {
+	idle, err := getIdleSecondsMac()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-time.Duration(idle * float64(time.Second))), nil
+}
+
+const macFrontWindowScript = `tell application "System Events"
+	set p to first application process whose frontmost is true
+	set n to name of p
+	set t to ""
+	try
+		set t to name of front window of p
+	end try
+	set i to unix id of p
+end tell
+return n & linefeed & t & linefeed & i`
+
+// activeWindow reports the focused application and window title, plus the
+// directory and git repository its process is working in when known.
+func activeWindow(cfg Config) (ActiveWindow, error) {
+	w, err := probeWindow(cfg)
+	if err != nil {
+		return w, err
+	}
+	if w.PID > 0 {
+		w.Dir = processDir(w.PID)
+		w.Repo = gitRepoName(w.Dir)
+	}
+	return w, nil
+}
+
+// probeWindow asks the platform for the focused window. A configured window
+// command takes precedence over the built-in probes.
+func probeWindow(cfg Config) (ActiveWindow, error) {
+	if cfg.WindowCommand != "" {
+		out, err := exec.Command("/bin/sh", "-c", cfg.WindowCommand).Output()
+		if err != nil {
+			return ActiveWindow{}, err
+		}
+		return parseWindowLines(string(out))
+	}
+	switch {
+	case runtime.GOOS == "darwin":
+		out, err := exec.Command("osascript", "-e", macFrontWindowScript).Output()
+		if err != nil {
+			return ActiveWindow{}, err
+		}
+		return parseWindowLines(string(out))
+	case os.Getenv("SWAYSOCK") != "":
+		return activeWindowIPC("swaymsg")
+	case os.Getenv("I3SOCK") != "":
+		return activeWindowIPC("i3-msg")
+	case os.Getenv("DISPLAY") != "":
+		return activeWindowX11()
+	}
+	return ActiveWindow{}, fmt.Errorf("no active window source for %s", runtime.GOOS)
+}
+
+func parseWindowLines(out string) (ActiveWindow, error) {
+	lines := strings.SplitN(strings.TrimRight(out, "\n"), "\n", 3)
+	w := ActiveWindow{App: strings.TrimSpace(lines[0])}
+	if len(lines) > 1 {
+		w.Title = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		w.PID, _ = strconv.Atoi(strings.TrimSpace(lines[2]))
+	}
+	if w.App == "" {
+		return ActiveWindow{}, fmt.Errorf("no focused application")
+	}
+	return w, nil
+}
+
+var (
+	xActiveRe = regexp.MustCompile(`window id # (0x[0-9a-fA-F]+)`)
+	xClassRe  = regexp.MustCompile(`WM_CLASS\(STRING\) = "([^"]*)"(?:, "([^"]*)")?`)
+	xNameRe   = regexp.MustCompile(`_NET_WM_NAME\(UTF8_STRING\) = "(.*)"`)
+	xPIDRe    = regexp.MustCompile(`_NET_WM_PID\(CARDINAL\) = ([0-9]+)`)
+)
+
+// activeWindowX11 follows _NET_ACTIVE_WINDOW on the root window via xprop.
+func activeWindowX11() (ActiveWindow, error) {
+	out, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
+	if err != nil {
+		return ActiveWindow{}, err
+	}
+	m := xActiveRe.FindStringSubmatch(string(out))
+	if len(m) != 2 || m[1] == "0x0" {
+		return ActiveWindow{}, fmt.Errorf("no active window")
+	}
+	out, err = exec.Command("xprop", "-id", m[1], "WM_CLASS", "_NET_WM_NAME", "_NET_WM_PID").Output()
+	if err != nil {
+		return ActiveWindow{}, err
+	}
+	var w ActiveWindow
+	if c := xClassRe.FindStringSubmatch(string(out)); c != nil {
+		w.App = c[1]
+		if c[2] != "" {
+			w.App = c[2]
+		}
+	}
+	if n := xNameRe.FindStringSubmatch(string(out)); n != nil {
+		w.Title = n[1]
+	}
+	if p := xPIDRe.FindStringSubmatch(string(out)); p != nil {
+		w.PID, _ = strconv.Atoi(p[1])
+	}
+	if w.App == "" {
+		return ActiveWindow{}, fmt.Errorf("active window has no WM_CLASS")
+	}
+	return w, nil
+}
+
+type ipcNode struct {
+	Focused          bool              `json:"focused"`
+	Name             string            `json:"name"`
+	PID              int               `json:"pid"`
+	AppID            string            `json:"app_id"`
+	WindowProperties map[string]string `json:"window_properties"`
+	Nodes            []ipcNode         `json:"nodes"`
+	FloatingNodes    []ipcNode         `json:"floating_nodes"`
+}
+
+// activeWindowIPC walks the sway/i3 layout tree for the focused container.
+func activeWindowIPC(tool string) (ActiveWindow, error) {
+	out, err := exec.Command(tool, "-t", "get_tree").Output()
+	if err != nil {
+		return ActiveWindow{}, err
+	}
+	var root ipcNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		return ActiveWindow{}, err
+	}
+	var find func(n ipcNode) *ipcNode
+	find = func(n ipcNode) *ipcNode {
+		if n.Focused {
+			return &n
+		}
+		for _, c := range append(n.Nodes, n.FloatingNodes...) {
+			if f := find(c); f != nil {
+				return f
+			}
+		}
+		return nil
+	}
+	n := find(root)
+	if n == nil {
+		return ActiveWindow{}, fmt.Errorf("no focused window")
+	}
+	w := ActiveWindow{App: n.AppID, Title: n.Name, PID: n.PID}
+	if w.App == "" {
+		w.App = n.WindowProperties["class"]
+	}
+	if w.App == "" {
+		return ActiveWindow{}, fmt.Errorf("focused container is not a window")
+	}
+	return w, nil
+}
+
+// recordWindow extends the last window span when the same window is still
+// focused, otherwise starts a new one covering this sample.
+func recordWindow(s *Store, now time.Time, w ActiveWindow) {
+	ts := now.Unix()
+	if n := len(s.Windows); n > 0 {
+		last := &s.Windows[n-1]
+		if last.App == w.App && last.Title == w.Title && last.Dir == w.Dir && ts-last.End <= sampleSeconds {
+			last.End = ts + sampleSeconds
+			return
+		}
+	}
+	s.Windows = append(s.Windows, WindowSpan{
+		Start: ts,
+		End:   ts + sampleSeconds,
+		App:   w.App,
+		Title: w.Title,
+		Dir:   w.Dir,
+		Repo:  w.Repo,
+	})
+}
+
+func windowDays(cfg Config) int {
+	if cfg.WindowDays > 0 {
+		return cfg.WindowDays
+	}
+	return 180
+}
+
+// compactWindows keeps the focused windows before today only per bin, which
+// is all windowAt looks at, and drops those older than the window days.
+func compactWindows(s *Store, now time.Time) {
+	cutoff := startOfDay(now).Unix()
+	keepFrom := startOfDay(now).AddDate(0, 0, -windowDays(s.Config)).Unix()
+	i := 0
+	for i < len(s.Windows) && s.Windows[i].End <= cutoff {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	old := &Store{Windows: s.Windows[:i]}
+	var out []WindowSpan
+	for t := floorToBin(time.Unix(max(old.Windows[0].Start, keepFrom), 0)); t.Unix() < cutoff; t = t.Add(binMinutes * time.Minute) {
+		w, ok := windowAt(old, t)
+		if !ok {
+			continue
+		}
+		w.Start, w.End = t.Unix(), t.Add(binMinutes*time.Minute).Unix()
+		if n := len(out); n > 0 && out[n-1].End == w.Start && out[n-1].App == w.App && out[n-1].Title == w.Title && out[n-1].Dir == w.Dir {
+			out[n-1].End = w.End
 		} else {
-			dayStyle = idleStyle
-			indicator = "🔴"
+			out = append(out, w)
+		}
+	}
+	s.Windows = append(out, s.Windows[i:]...)
+}
+
+// processDir follows the newest child of pid down to a leaf (a terminal's
+// shell or editor) and returns that process's working directory.
+func processDir(pid int) string {
+	for depth := 0; depth < 8; depth++ {
+		children := childPIDs(pid)
+		if len(children) == 0 {
+			break
+		}
+		pid = children[len(children)-1]
+	}
+	if dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid)); err == nil {
+		return dir
+	}
+	out, err := exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "cwd", "-Fn").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "n") {
+			return line[1:]
+		}
+	}
+	return ""
+}
+
+func childPIDs(pid int) []int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		data, err = exec.Command("pgrep", "-P", strconv.Itoa(pid)).Output()
+		if err != nil {
+			return nil
+		}
+	}
+	var pids []int
+	for _, f := range strings.Fields(string(data)) {
+		if n, err := strconv.Atoi(f); err == nil {
+			pids = append(pids, n)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// gitRepoName returns the name of the git work tree containing dir, if any.
+func gitRepoName(dir string) string {
+	for dir != "" && dir != "/" && dir != "." {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return filepath.Base(dir)
+		}
+		dir = filepath.Dir(dir)
+	}
+	return ""
+}
+
+// windowAt returns the window that had focus for most of the bin starting at t.
+func windowAt(s *Store, t time.Time) (WindowSpan, bool) {
+	start := t.Unix()
+	end := t.Add(binMinutes * time.Minute).Unix()
+	i := sort.Search(len(s.Windows), func(i int) bool { return s.Windows[i].End > start })
+	var best WindowSpan
+	var bestSecs int64
+	for ; i < len(s.Windows) && s.Windows[i].Start < end; i++ {
+		w := s.Windows[i]
+		secs := min(w.End, end) - max(w.Start, start)
+		if secs > bestSecs {
+			best, bestSecs = w, secs
+		}
+	}
+	return best, bestSecs > 0
+}
+
+// rulesPath returns the rules file that sits next to the store.
+func rulesPath(storePath string) string {
+	return strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".rules.json"
+}
+
+// loadRules reads the rules file, where a missing file means no rules, and
+// appends the config's window rules after them.
+func loadRules(path string, cfg Config) ([]Rule, error) {
+	var rules []Rule
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, wr := range cfg.WindowRules {
+		rules = append(rules, Rule{Name: "window rule " + wr.Tag, Tag: wr.Tag, App: wr.App, Title: wr.Title})
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			name := rules[i].Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+	return rules, nil
+}
+
+func (r *Rule) compile() error {
+	var err error
+	r.from, r.to = -1, -1
+	if r.From != "" {
+		if r.from, err = parseTimeToMinutes(r.From); err != nil {
+			return err
+		}
+	}
+	if r.To != "" {
+		if r.to, err = parseTimeToMinutes(r.To); err != nil {
+			return err
+		}
+	}
+	patterns := []struct {
+		src string
+		dst **regexp.Regexp
+	}{{r.App, &r.app}, {r.Title, &r.title}, {r.Dir, &r.dir}, {r.Repo, &r.repo}, {r.Session, &r.sessionRe}}
+	for _, p := range patterns {
+		if p.src == "" {
+			continue
+		}
+		if *p.dst, err = regexp.Compile(p.src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(c RuleContext) bool {
+	if r.from >= 0 || r.to >= 0 {
+		m := c.Time.Hour()*60 + c.Time.Minute()
+		from, to := max(r.from, 0), r.to
+		if to < 0 {
+			to = 24 * 60
+		}
+		if from <= to && (m < from || m >= to) {
+			return false
+		}
+		if from > to && m < from && m >= to {
+			return false
+		}
+	}
+	if len(r.Weekdays) > 0 && !isWorkDay(c.Time, r.Weekdays) {
+		return false
+	}
+	// A condition on something we don't know (no window, no session) never matches.
+	checks := []struct {
+		re *regexp.Regexp
+		v  string
+	}{{r.app, c.App}, {r.title, c.Title}, {r.dir, c.Dir}, {r.repo, c.Repo}, {r.sessionRe, c.Session}}
+	for _, ch := range checks {
+		if ch.re != nil && (ch.v == "" || !ch.re.MatchString(ch.v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRules returns the winning rule for c; rules must come from loadRules.
+func matchRules(rules []Rule, c RuleContext) *Rule {
+	for i := range rules {
+		if rules[i].matches(c) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// ruleContextAt rebuilds what the tracker knew about the bin at t from the
+// recorded window spans and sessions.
+func ruleContextAt(s *Store, t time.Time) RuleContext {
+	mid := t.Add(binMinutes * time.Minute / 2)
+	c := RuleContext{Time: t, Session: sessionAt(s, mid)}
+	if w, ok := windowAt(s, t); ok {
+		c.App, c.Title, c.Dir, c.Repo = w.App, w.Title, w.Dir, w.Repo
+	}
+	return c
+}
+
+func isRuleSource(source string) bool {
+	return source == "rule" || source == "window"
+}
+
+func sessionAt(s *Store, t time.Time) string {
+	ts := t.Unix()
+	for i := len(s.Sessions) - 1; i >= 0; i-- {
+		se := s.Sessions[i]
+		if ts >= se.Start && (se.End == 0 || ts < se.End) {
+			return se.Name
+		}
+	}
+	return ""
+}
+
+func activeSession(s *Store) *Session {
+	for i := len(s.Sessions) - 1; i >= 0; i-- {
+		if s.Sessions[i].End == 0 {
+			return &s.Sessions[i]
+		}
+	}
+	return nil
+}
+
+// upsertBin records a sample and reports whether it turned the bin to working.
+func upsertBin(s *Store, binStart time.Time, working bool) bool {
+	k := strconv.FormatInt(binStart.Unix(), 10)
+	cur := s.Bins[k]
+	if working && cur == 0 {
+		s.Bins[k] = 1
+		return true
+	} else if cur == 0 && !working {
+		if _, ok := s.Bins[k]; !ok {
+			s.Bins[k] = 0
+		}
+	}
+	return false
+}
+
+func fetchBins(s *Store, start, end time.Time) map[time.Time]int {
+	res := make(map[time.Time]int)
+
+	for k, v := range s.Bins {
+		ts, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			continue
+		}
+		t := time.Unix(ts, 0)
+		if !t.Before(start) && t.Before(end) {
+			res[t] = v
+		}
+	}
+
+	// Ranges merged from other devices come last: there working beats idle.
+	for _, merged := range []bool{false, true} {
+		for _, r := range s.Ranges {
+			if r.TagOnly || r.Override || (r.Device != "") != merged {
+				continue
+			}
+			rStart := time.Unix(r.Start, 0)
+			rEnd := time.Unix(r.End, 0)
+
+			if rEnd.Before(start) || !rStart.Before(end) {
+				continue
+			}
+
+			for cur := floorToBin(rStart); cur.Before(rEnd) && cur.Before(end); cur = cur.Add(binMinutes * time.Minute) {
+				if cur.Before(start) {
+					continue
+				}
+				if merged {
+					res[cur] = max(res[cur], r.Status)
+				} else {
+					res[cur] = r.Status
+				}
+			}
+		}
+	}
+
+	// Meetings count as working time even when the machine sat idle.
+	now := time.Now()
+	for _, mt := range s.Meetings {
+		mStart := time.Unix(mt.Start, 0)
+		mEnd := time.Unix(mt.End, 0)
+		if !mStart.Before(end) || !mEnd.After(start) {
+			continue
+		}
+		for cur := floorToBin(mStart); cur.Before(mEnd) && cur.Before(end) && cur.Before(now); cur = cur.Add(binMinutes * time.Minute) {
+			if !cur.Before(start) {
+				res[cur] = 1
+			}
+		}
+	}
+
+	// Statuses set by hand win over everything tracked.
+	for _, r := range s.Ranges {
+		if !r.Override || r.End <= start.Unix() || r.Start >= end.Unix() {
+			continue
+		}
+		for cur := floorToBin(time.Unix(r.Start, 0)); cur.Before(time.Unix(r.End, 0)) && cur.Before(end); cur = cur.Add(binMinutes * time.Minute) {
+			if !cur.Before(start) {
+				res[cur] = r.Status
+			}
+		}
+	}
+
+	return res
+}
+
+// meetingAt returns the meeting overlapping the bin starting at t, if any.
+func meetingAt(s *Store, t time.Time) *Meeting {
+	start := t.Unix()
+	end := t.Add(binMinutes * time.Minute).Unix()
+	for i := range s.Meetings {
+		if s.Meetings[i].Start < end && s.Meetings[i].End > start {
+			return &s.Meetings[i]
+		}
+	}
+	return nil
+}
+
+// binIndex answers tagRangeAt, overrideAt, splitAt, meetingAt and binTags for
+// the bins of one window from lookups built in a single pass over the ranges
+// and meetings, for views that ask about every bin.
+type binIndex struct {
+	s        *Store
+	tag      map[int64]int
+	override map[int64]int
+	split    map[int64]bool
+	meeting  map[int64]*Meeting
+}
+
+func indexBins(s *Store, start, end time.Time) binIndex {
+	ix := binIndex{s: s, tag: map[int64]int{}, override: map[int64]int{}, split: map[int64]bool{}, meeting: map[int64]*Meeting{}}
+	// each calls f for the bins in [start, end) that overlap [from, to), or
+	// with starts only for the bins starting inside it.
+	each := func(from, to int64, starts bool, f func(t int64)) {
+		cur := floorToBin(time.Unix(max(from, start.Unix()), 0))
+		if starts && cur.Unix() < from {
+			cur = cur.Add(binMinutes * time.Minute)
+		}
+		for ; cur.Unix() < to && cur.Before(end); cur = cur.Add(binMinutes * time.Minute) {
+			f(cur.Unix())
+		}
+	}
+	for i, r := range s.Ranges {
+		switch {
+		case r.Split:
+			ix.split[r.Start] = true
+		case r.Override:
+			each(r.Start, r.End, true, func(t int64) {
+				if _, ok := ix.override[t]; !ok {
+					ix.override[t] = i
+				}
+			})
+		}
+		if isTagRange(r) {
+			// the first manual range wins, otherwise the first automatic one
+			each(r.Start, r.End, true, func(t int64) {
+				if j, ok := ix.tag[t]; !ok || s.Ranges[j].Source != "" && r.Source == "" {
+					ix.tag[t] = i
+				}
+			})
+		}
+	}
+	for i := range s.Meetings {
+		mt := &s.Meetings[i]
+		each(mt.Start, mt.End, false, func(t int64) {
+			if ix.meeting[t] == nil {
+				ix.meeting[t] = mt
+			}
+		})
+	}
+	return ix
+}
+
+func (ix binIndex) tagRangeAt(t time.Time) int {
+	if i, ok := ix.tag[t.Unix()]; ok {
+		return i
+	}
+	return -1
+}
+
+func (ix binIndex) overrideAt(t time.Time) int {
+	if i, ok := ix.override[t.Unix()]; ok {
+		return i
+	}
+	return -1
+}
+
+func (ix binIndex) splitAt(t time.Time) bool { return ix.split[t.Unix()] }
+
+func (ix binIndex) meetingAt(t time.Time) *Meeting { return ix.meeting[t.Unix()] }
+
+func (ix binIndex) binTags(t time.Time) []string {
+	if idx := ix.tagRangeAt(t); idx >= 0 {
+		return ix.s.Ranges[idx].Tags
+	}
+	if mt := ix.meetingAt(t); mt != nil {
+		return parseTags(mt.Tag)
+	}
+	return nil
+}
+
+// binTags returns the tags shown for the bin at t: those of a tag range if
+// there is one, otherwise the tag of a meeting covering it.
+func binTags(s *Store, t time.Time) []string {
+	if idx := tagRangeAt(s, t); idx >= 0 {
+		return s.Ranges[idx].Tags
+	}
+	if mt := meetingAt(s, t); mt != nil {
+		return parseTags(mt.Tag)
+	}
+	return nil
+}
+
+// tagShares credits one bin's minutes to its tags: each tag gets the whole bin,
+// or an equal part of it with tag_allocation=split. Untagged bins count as (untagged).
+func tagShares(c Config, tags []string) map[string]float64 {
+	if len(tags) == 0 {
+		return map[string]float64{"(untagged)": binMinutes}
+	}
+	share := float64(binMinutes)
+	if c.TagAllocation == "split" {
+		share /= float64(len(tags))
+	}
+	out := map[string]float64{}
+	for _, tag := range tags {
+		out[tag] = share
+	}
+	return out
+}
+
+// roundMinutes turns accumulated tag shares into whole minutes.
+func roundMinutes(shares map[string]float64) map[string]int {
+	out := map[string]int{}
+	for tag, mins := range shares {
+		out[tag] = int(mins + 0.5)
+	}
+	return out
+}
+
+// tagQuery matches a bin's tags against an OR of AND groups, e.g.
+// "client:acme AND type:meeting OR internal". A term also matches subtags.
+type tagQuery [][]string
+
+var (
+	queryOr  = regexp.MustCompile(`(?i)\s+OR\s+`)
+	queryAnd = regexp.MustCompile(`(?i)\s+AND\s+`)
+)
+
+func parseTagQuery(q string) tagQuery {
+	var out tagQuery
+	for _, group := range queryOr.Split(strings.TrimSpace(q), -1) {
+		var terms []string
+		for _, term := range queryAnd.Split(group, -1) {
+			if term = normalizeTag(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) > 0 {
+			out = append(out, terms)
+		}
+	}
+	return out
+}
+
+// match reports whether tags satisfy the query; an empty query matches everything.
+func (q tagQuery) match(tags []string) bool {
+	if len(q) == 0 {
+		return true
+	}
+	has := func(term string) bool {
+		if term == "(untagged)" {
+			return len(tags) == 0
+		}
+		for _, tag := range tags {
+			if tagMatches(tag, term) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, group := range q {
+		all := true
+		for _, term := range group {
+			all = all && has(term)
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// credit returns the minutes of a bin with tags that count towards the query:
+// the shares of the tags named by its terms, never more than the bin itself.
+func (q tagQuery) credit(c Config, tags []string) float64 {
+	if !q.match(tags) {
+		return 0
+	}
+	if len(q) == 0 {
+		return binMinutes
+	}
+	total := 0.0
+	for tag, mins := range tagShares(c, tags) {
+		for _, group := range q {
+			if slices.ContainsFunc(group, func(term string) bool { return tagMatches(tag, term) }) {
+				total += mins
+				break
+			}
+		}
+	}
+	return min(total, binMinutes)
+}
+
+func reportToday(s *Store) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := now
+	bins := fetchBins(s, start, end)
+
+	// build full sequence of bins and merge contiguous
+	var seq []time.Time
+	for cur := floorToBin(start); cur.Before(floorToBin(end)); cur = cur.Add(binMinutes * time.Minute) {
+		seq = append(seq, cur)
+	}
+	status := map[time.Time]int{}
+	for _, t := range seq {
+		status[t] = 0
+	}
+	for t, v := range bins {
+		status[t] = v
+	}
+	if s.Config.ShowMeetings {
+		ix := indexBins(s, start, end)
+		for _, t := range seq {
+			if ix.meetingAt(t) != nil && ix.overrideAt(t) < 0 {
+				status[t] = meetingStatus
+			}
+		}
+	}
+
+	fmt.Println(now.Format("Date : Jan 2, 2006 , Monday"))
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("%-15s | %-12s | %s\n", "Time Range", "Duration", "Description")
+	fmt.Println(strings.Repeat("-", 50))
+
+	totalWork := 0
+	for i := 0; i < len(seq); {
+		startBin := seq[i]
+		st := status[startBin]
+		j := i
+		for j < len(seq) && status[seq[j]] == st {
+			j++
+		}
+		endBin := seq[j-1].Add(binMinutes * time.Minute)
+		mins := int(endBin.Sub(startBin).Minutes())
+		desc := "idle"
+		if st == 1 {
+			desc = "working"
+			totalWork += mins
+		} else if st == meetingStatus {
+			desc = "meeting"
+			totalWork += mins
+		}
+		fmt.Printf("%s-%-7s | %-12s | %s\n", startBin.Format("15:04"), endBin.Format("15:04"), humanDuration(mins), desc)
+		i = j
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Total working today : %s\n", humanDuration(totalWork))
+	if goal := dailyGoal(s, now); goal > 0 {
+		fmt.Printf("Daily goal progress: %s\n", formatPercentage(totalWork, goal))
+	} else if off := dayOff(s, now); off != nil {
+		fmt.Printf("Day off: %s\n", off.label())
+	}
+	printAppBreakdown(s, start, end)
+}
+
+func create7DayWorkingHours(s *Store, width int) string {
+	now := time.Now()
+	content := "📊 LAST 7 DAYS\n\n"
+
+	totalWeekHours := 0
+
+	for dayIndex := 0; dayIndex < 7; dayIndex++ {
+		targetDay := now.AddDate(0, 0, -(6 - dayIndex)) // Start from 6 days ago to today
+
+		// Get working minutes for this day
+		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
+		dayEnd := dayStart.Add(24 * time.Hour)
+		bins := fetchBins(s, dayStart, dayEnd)
+
+		workMins := 0
+		for _, v := range bins {
+			if v == 1 {
+				workMins += binMinutes
+			}
+		}
+		totalWeekHours += workMins
+
+		// Format the day
+		dayName := targetDay.Format("Mon")
+		dateStr := targetDay.Format("Jan 2")
+
+		// Color coding based on work hours and if it's a work day
+		var dayStyle lipgloss.Style
+		var indicator string
+
+		goal := dailyGoal(s, targetDay)
+		off := dayOff(s, targetDay)
+
+		if off != nil && !off.Half {
+			dayStyle = offStyle
+			indicator = "🏖️"
+		} else if workMins == 0 {
+			dayStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
+			indicator = "⚫"
+		} else if goal > 0 && workMins >= goal {
+			dayStyle = workingStyle
+			indicator = "✅"
+		} else if workMins > 0 {
+			dayStyle = progressStyle
+			indicator = "🟡"
+		} else {
+			dayStyle = idleStyle
+			indicator = "🔴"
+		}
+
+		// Format working hours
+		hoursStr := humanDuration(workMins)
+		if workMins == 0 {
+			hoursStr = "No work"
+		}
+		if off != nil {
+			hoursStr += " (" + off.label() + ")"
+		}
+
+		content += fmt.Sprintf("%s %s %s: %s\n",
+			indicator,
+			dayName,
+			dateStr,
+			dayStyle.Render(hoursStr))
+	}
+
+	content += fmt.Sprintf("\nTotal: %s", workingStyle.Render(humanDuration(totalWeekHours)))
+
+	return content
+}
+
+// Daily aggregate table used for week/month ranges
+func reportAggregateDaily(s *Store, start time.Time, days int, title string) {
+	fmt.Println(title)
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("%-15s | %s\n", "Date", "Working Time")
+	fmt.Println(strings.Repeat("-", 50))
+	total := 0
+	for i := 0; i < days; i++ {
+		d := start.AddDate(0, 0, i)
+		dayStart := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
+		dayEnd := dayStart.Add(24 * time.Hour)
+		bins := fetchBins(s, dayStart, dayEnd)
+		mins := 0
+		for _, v := range bins {
+			if v == 1 {
+				mins += binMinutes
+			}
+		}
+		total += mins
+		if off := dayOff(s, d); off != nil {
+			fmt.Printf("%-15s | %s (%s)\n", d.Format("2006-01-02"), humanDuration(mins), off.label())
+		} else {
+			fmt.Printf("%-15s | %s\n", d.Format("2006-01-02"), humanDuration(mins))
+		}
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	lower := strings.ToLower(title)
+	noun := "range"
+	if strings.Contains(lower, "week") {
+		noun = "week"
+	}
+	if strings.Contains(lower, "month") {
+		noun = "month"
+	}
+	fmt.Printf("Total working %s : %s\n", noun, humanDuration(total))
+	expectedMins := periodGoal(s, start, start.AddDate(0, 0, days))
+	if expectedMins > 0 {
+		fmt.Printf("Goal progress: %s\n", formatPercentage(total, expectedMins))
+	}
+	printAppBreakdown(s, start, start.AddDate(0, 0, days))
+	fmt.Println()
+	fmt.Println("When you worked")
+	fmt.Println(strings.Repeat("-", 53))
+	fmt.Println(renderHourHeatmap(hourHeatmap(s, start, start.AddDate(0, 0, days))))
+}
+
+// Year report: monthly totals
+func reportYearMonthly(s *Store, year int) {
+	loc := time.Now().Location()
+	fmt.Printf("for year %d (monthly totals)\n", year)
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("%-15s | %s\n", "Month", "Working Time")
+	fmt.Println(strings.Repeat("-", 50))
+	total := 0
+	for m := time.January; m <= time.December; m++ {
+		start := time.Date(year, m, 1, 0, 0, 0, 0, loc)
+		next := start.AddDate(0, 1, 0)
+		bins := fetchBins(s, start, next)
+		mins := 0
+		for _, v := range bins {
+			if v == 1 {
+				mins += binMinutes
+			}
+		}
+		total += mins
+		fmt.Printf("%-15s | %s\n", start.Format("Jan"), humanDuration(mins))
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Total working year : %s\n", humanDuration(total))
+	expectedMins := 0
+	for m := time.January; m <= time.December; m++ {
+		start := time.Date(year, m, 1, 0, 0, 0, 0, loc)
+		expectedMins += periodGoal(s, start, start.AddDate(0, 1, 0))
+	}
+	fmt.Printf("Goal progress: %s\n", formatPercentage(total, expectedMins))
+	printAppBreakdown(s, time.Date(year, 1, 1, 0, 0, 0, 0, loc), time.Date(year+1, 1, 1, 0, 0, 0, 0, loc))
+}
+
+func report(s *Store, rng, by, tag string) {
+	now := time.Now()
+	if tag != "" {
+		start, end, ok := rangeBounds(rng, now)
+		if !ok {
+			fmt.Printf("Unknown range '%s'\n", rng)
+			return
+		}
+		reportByTag(s, start, end, tag)
+		return
+	}
+	if by != "" {
+		start, end, ok := rangeBounds(rng, now)
+		if !ok {
+			fmt.Printf("Unknown range '%s'\n", rng)
+			return
+		}
+		switch by {
+		case "repo":
+			reportByRepo(s, start, end)
+		case "hour":
+			if rng == "today" {
+				// a single day says little about habits
+				start, end, _ = rangeBounds(fmt.Sprintf("%dd", heatmapDays(s.Config)), now)
+			}
+			reportByHour(s, start, end)
+		case "focus":
+			reportFocus(s, start, end)
+		default:
+			fmt.Printf("Unknown grouping '%s'\n", by)
+		}
+		return
+	}
+	switch rng {
+	case "today":
+		reportToday(s)
+	case "week":
+		start := startOfWeek(now)
+		reportAggregateDaily(s, start, 7, fmt.Sprintf("for week starting %s", start.Format("2006-01-02")))
+	case "month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		next := start.AddDate(0, 1, 0)
+		days := int(next.Sub(start).Hours() / 24)
+		reportAggregateDaily(s, start, days, fmt.Sprintf("for month %s", start.Format("2006-01")))
+	case "last-week", "last-month":
+		start, end, _ := rangeBounds(rng, now)
+		days := int(end.Sub(start).Hours()/24 + 0.5)
+		reportAggregateDaily(s, start, days, fmt.Sprintf("for %s starting %s", strings.TrimPrefix(rng, "last-"), start.Format("2006-01-02")))
+	case "year":
+		reportYearMonthly(s, now.Year())
+	default:
+		fmt.Printf("Unknown range '%s'\n", rng)
+	}
+}
+
+// hourHeatmap sums the working minutes per weekday (Monday first) and hour of day.
+func hourHeatmap(s *Store, start, end time.Time) (grid [7][24]int) {
+	for t, v := range fetchBins(s, start, end) {
+		if v == 1 {
+			grid[(int(t.Weekday())+6)%7][t.Hour()] += binMinutes
+		}
+	}
+	return grid
+}
+
+// heatmapDays is the window the hour heatmap covers unless a range is given.
+func heatmapDays(cfg Config) int {
+	if cfg.HeatmapDays > 0 {
+		return cfg.HeatmapDays
+	}
+	return 90
+}
+
+// peakHours returns the hours of day with the most working time, busiest first.
+func peakHours(grid [7][24]int, n int) []int {
+	var totals [24]int
+	for _, row := range grid {
+		for h, mins := range row {
+			totals[h] += mins
+		}
+	}
+	var hours []int
+	for h := range totals {
+		if totals[h] > 0 {
+			hours = append(hours, h)
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return totals[hours[i]] > totals[hours[j]] })
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// renderHourHeatmap draws the grid with one two-character cell per hour; the
+// shading works without colors too. Peak hours are marked below the grid.
+func renderHourHeatmap(grid [7][24]int) string {
+	most := 0
+	for _, row := range grid {
+		for _, mins := range row {
+			most = max(most, mins)
+		}
+	}
+	peaks := peakHours(grid, 3)
+	isPeak := map[int]bool{}
+	for _, h := range peaks {
+		isPeak[h] = true
+	}
+	shades := []string{"·", "░", "▒", "▓", "█"}
+
+	var b strings.Builder
+	b.WriteString("     ")
+	for h := 0; h < 24; h += 3 {
+		b.WriteString(fmt.Sprintf("%-6s", fmt.Sprintf("%02d", h)))
+	}
+	b.WriteString("\n")
+	for d, row := range grid {
+		b.WriteString(strings.ToUpper(weekdayNames[d][:1]) + weekdayNames[d][1:] + "  ")
+		for _, mins := range row {
+			level := 0
+			if mins > 0 {
+				level = min(1+mins*4/(most+1), 4)
+			}
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(heatColors[level])).Render(strings.Repeat(shades[level], 2)))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("     ")
+	for h := 0; h < 24; h++ {
+		if isPeak[h] {
+			b.WriteString(progressStyle.Render("▲▲"))
+		} else {
+			b.WriteString("  ")
+		}
+	}
+	b.WriteString("\n")
+	if len(peaks) == 0 {
+		b.WriteString("No working time in this window")
+		return b.String()
+	}
+	var labels []string
+	for _, h := range peaks {
+		labels = append(labels, fmt.Sprintf("%02d:00-%02d:00", h, h+1))
+	}
+	b.WriteString("Peak focus hours: " + progressStyle.Render(strings.Join(labels, ", ")))
+	return b.String()
+}
+
+func reportByHour(s *Store, start, end time.Time) {
+	fmt.Printf("Working time by hour of day, %s to %s\n", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Println(strings.Repeat("-", 53))
+	fmt.Println(renderHourHeatmap(hourHeatmap(s, start, end)))
+}
+
+// rangeBounds returns [start, end) for a report range name.
+func rangeBounds(rng string, now time.Time) (start, end time.Time, ok bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch rng {
+	case "today":
+		return today, today.AddDate(0, 0, 1), true
+	case "week":
+		start = startOfWeek(now)
+		return start, start.AddDate(0, 0, 7), true
+	case "month":
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0), true
+	case "year":
+		start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(1, 0, 0), true
+	case "last-week", "last-month":
+		start, _, _ = rangeBounds(strings.TrimPrefix(rng, "last-"), now)
+		if rng == "last-week" {
+			return start.AddDate(0, 0, -7), start, true
+		}
+		return start.AddDate(0, -1, 0), start, true
+	}
+	// Nd: the last N days including today
+	if days, err := strconv.Atoi(strings.TrimSuffix(rng, "d")); err == nil && strings.HasSuffix(rng, "d") && days > 0 {
+		return today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func todayTotals(s *Store) (workMins, idleMins int) {
+	now := time.Now()
+	return dayTotals(s, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), now)
+}
+
+// dayTotals splits the bins in [start, end) into working and idle minutes.
+func dayTotals(s *Store, start, end time.Time) (workMins, idleMins int) {
+	var seq []time.Time
+	for cur := floorToBin(start); cur.Before(floorToBin(end)); cur = cur.Add(binMinutes * time.Minute) {
+		seq = append(seq, cur)
+	}
+	status := map[time.Time]int{}
+	for _, t := range seq {
+		status[t] = 0
+	}
+	bins := fetchBins(s, start, end)
+	for t, v := range bins {
+		status[t] = v
+	}
+	for _, t := range seq {
+		if status[t] == 1 {
+			workMins += binMinutes
+		} else {
+			idleMins += binMinutes
+		}
+	}
+	return
+}
+
+func ensureStartupAtLogin(execPath string) {
+	usr, err := user.Current()
+	if err != nil {
+		return
+	}
+	uid := usr.Uid
+	base := strings.TrimSuffix(filepath.Base(execPath), filepath.Ext(execPath))
+	base = strings.ToLower(strings.ReplaceAll(base, " ", "-"))
+	label := "com." + base + ".autostart"
+
+	agentsDir := filepath.Join(usr.HomeDir, "Library", "LaunchAgents")
+	plistPath := filepath.Join(agentsDir, label+".plist")
+
+	installed := false
+	if _, err := os.Stat(plistPath); err == nil {
+		if err := exec.Command("launchctl", "print", "gui/"+uid+"/"+label).Run(); err == nil {
+			installed = true
+		}
+	}
+	if installed {
+		return
+	}
+
+	fmt.Print("[startup] This app is not set to launch at login. Add it now? [y/N]: ")
+	rd := bufio.NewReader(os.Stdin)
+	ans, _ := rd.ReadString('\n')
+	ans = strings.TrimSpace(strings.ToLower(ans))
+	if ans != "y" && ans != "yes" {
+		fmt.Println("[startup] Skipping adding to startup.")
+		return
+	}
+
+	_ = os.MkdirAll(agentsDir, 0755)
+	outLog := filepath.Join(agentsDir, label+".out.log")
+	errLog := filepath.Join(agentsDir, label+".err.log")
+	dataFile := filepath.Join(filepath.Dir(execPath), "timetrackcli.json")
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0"><dict>
+  <key>Label</key><string>%s</string>
+  <key>ProgramArguments</key><array><string>%s</string><string>--file</string><string>%s</string></array>
+  <key>RunAtLoad</key><true/>
+  <key>KeepAlive</key><true/>
+  <key>WorkingDirectory</key><string>%s</string>
+  <key>StandardOutPath</key><string>%s</string>
+  <key>StandardErrorPath</key><string>%s</string>
+</dict></plist>`, label, execPath, dataFile, filepath.Dir(execPath), outLog, errLog)
+
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		fmt.Println("[startup] Failed to write LaunchAgent:", err)
+		return
+	}
+
+	if err := exec.Command("launchctl", "bootstrap", "gui/"+uid, plistPath).Run(); err != nil {
+		_ = exec.Command("launchctl", "load", "-w", plistPath).Run()
+	}
+	_ = exec.Command("launchctl", "enable", "gui/"+uid+"/"+label).Run()
+	_ = exec.Command("launchctl", "kickstart", "-k", "gui/"+uid+"/"+label).Run()
+	fmt.Println("[startup] Added to login (LaunchAgents):", plistPath)
+}
+
+func parseTimeToMinutes(timeStr string) (int, error) {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time format, use HH:MM")
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	mins, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	return hours*60 + mins, nil
+}
+
+func parseWorkDays(workDaysStr string) ([]int, error) {
+	dayMap := map[string]int{
+		"mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6, "sun": 7,
+	}
+
+	var days []int
+	if strings.Contains(workDaysStr, "-") {
+		parts := strings.Split(workDaysStr, "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid range format")
+		}
+		start, ok1 := dayMap[strings.ToLower(parts[0])]
+		end, ok2 := dayMap[strings.ToLower(parts[1])]
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid day names")
+		}
+		for i := start; i <= end; i++ {
+			days = append(days, i)
+		}
+	} else {
+		parts := strings.Split(workDaysStr, ",")
+		for _, part := range parts {
+			day, ok := dayMap[strings.ToLower(strings.TrimSpace(part))]
+			if !ok {
+				return nil, fmt.Errorf("invalid day name: %s", part)
+			}
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func formatPercentage(workMins, goalMins int) string {
+	if goalMins == 0 {
+		return "0%"
+	}
+	pct := (workMins * 100) / goalMins
+	return fmt.Sprintf("%d%% of %s", pct, humanDuration(goalMins))
+}
+
+func isWorkDay(t time.Time, workDays []int) bool {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	for _, day := range workDays {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// dayOff returns the day-off entry for the date of t, if any.
+func dayOff(s *Store, t time.Time) *DayOff {
+	date := t.Format("2006-01-02")
+	for i := range s.DaysOff {
+		if s.DaysOff[i].Date == date {
+			return &s.DaysOff[i]
+		}
+	}
+	return nil
+}
+
+func (d DayOff) label() string {
+	reason := d.Reason
+	if reason == "" {
+		reason = "day off"
+	}
+	if d.Half {
+		reason = "half " + reason
+	}
+	return reason
+}
+
+var weekdayNames = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+func weekdayName(t time.Time) string {
+	return weekdayNames[(int(t.Weekday())+6)%7]
+}
+
+// scheduleAt returns the schedule in force on the day of t.
+func scheduleAt(s *Store, t time.Time) *Schedule {
+	date := t.Format("2006-01-02")
+	var found *Schedule
+	for i := range s.Config.Schedules {
+		sch := &s.Config.Schedules[i]
+		if sch.From <= date && (found == nil || sch.From > found.From) {
+			found = sch
+		}
+	}
+	return found
+}
+
+// scheduledGoal is the goal for the day of t before days off are applied.
+func scheduledGoal(s *Store, t time.Time) int {
+	if sch := scheduleAt(s, t); sch != nil && len(sch.Weekdays) > 0 {
+		return sch.Weekdays[weekdayName(t)]
+	}
+	if !isWorkDay(t, s.Config.WorkDays) {
+		return 0
+	}
+	return s.Config.DailyGoalMinutes
+}
+
+// dailyGoal is the number of minutes expected on the day of t: the scheduled
+// goal, halved or dropped for days off.
+func dailyGoal(s *Store, t time.Time) int {
+	goal := scheduledGoal(s, t)
+	if goal == 0 {
+		return 0
+	}
+	if off := dayOff(s, t); off != nil {
+		if !off.Half {
+			return 0
+		}
+		goal /= 2
+	}
+	return goal
+}
+
+// periodGoal is the goal for [start, end). Whole ISO weeks and calendar months
+// use an explicit weekly/monthly target when the schedule has one, scaled down
+// by the days off in the period; anything else is the sum of the daily goals.
+func periodGoal(s *Store, start, end time.Time) int {
+	sum, scheduled := 0, 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		sum += dailyGoal(s, d)
+		scheduled += scheduledGoal(s, d)
+	}
+	target := 0
+	if sch := scheduleAt(s, start); sch != nil {
+		switch {
+		case start.Weekday() == time.Monday && end.Equal(start.AddDate(0, 0, 7)):
+			target = sch.WeeklyMinutes
+		case start.Day() == 1 && end.Equal(start.AddDate(0, 1, 0)):
+			target = sch.MonthlyMinutes
+		}
+	}
+	if target == 0 {
+		return sum
+	}
+	if scheduled == 0 {
+		return target
+	}
+	return target * sum / scheduled
+}
+
+func findBestWorstDays(s *Store) (bestDay time.Time, bestMins int, worstDay time.Time, worstMins int) {
+	now := time.Now()
+	bestMins = -1
+	worstMins = 9999
+
+	for dayIndex := 0; dayIndex < 30; dayIndex++ {
+		targetDay := now.AddDate(0, 0, -(29 - dayIndex))
+
+		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
+		dayEnd := dayStart.Add(24 * time.Hour)
+		bins := fetchBins(s, dayStart, dayEnd)
+
+		workMins := 0
+		for _, v := range bins {
+			if v == 1 {
+				workMins += binMinutes
+			}
+		}
+
+		// Skip days with no work
+		if workMins == 0 {
+			continue
+		}
+
+		// Check for best day
+		if workMins > bestMins {
+			bestMins = workMins
+			bestDay = targetDay
+		}
+
+		// Check for worst day (but not zero)
+		if workMins < worstMins {
+			worstMins = workMins
+			worstDay = targetDay
+		}
+	}
+
+	return
+}
+
+func create30DayGrid(s *Store, width int) string {
+	now := time.Now()
+	grid := "📅 LAST 30 DAYS\n\n"
+
+	line := ""
+	for dayIndex := 0; dayIndex < 30; dayIndex++ {
+		targetDay := now.AddDate(0, 0, -(29 - dayIndex))
+
+		// Get working minutes for this day
+		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
+		dayEnd := dayStart.Add(24 * time.Hour)
+		bins := fetchBins(s, dayStart, dayEnd)
+
+		workMins := 0
+		for _, v := range bins {
+			if v == 1 {
+				workMins += binMinutes
+			}
+		}
+
+		// Determine symbol based on work hours
+		var symbol string
+
+		if workMins == 0 {
+			symbol = "⚫" // Gray circle - no data
+		} else if workMins < 120 { // Less than 2 hours
+			symbol = "⚪" // White circle
+		} else if workMins <= 300 { // 2-5 hours
+			symbol = "🟡" // Yellow circle
+		} else { // Above 5 hours
+			symbol = "🟢" // Green circle
+		}
+
+		// Use checkmark if it's a workday and meets goal
+		if goal := dailyGoal(s, targetDay); goal > 0 && workMins >= goal {
+			symbol = "✅"
+		}
+		if off := dayOff(s, targetDay); off != nil && !off.Half {
+			symbol = "🏖️"
+		}
+
+		line += symbol
+	}
+
+	grid += line + "\n\n"
+	grid += "⚫ No data  ⚪ <2hrs  🟡 2-5hrs  🟢 >5hrs  ✅ Goal met  🏖️ Day off"
+	return grid
+}
+
+// calculateFocusStats returns the longest focus session and how often work was
+// interrupted, ignoring idle gaps shorter than the configured focus gap.
+func calculateFocusStats(s *Store, start, now time.Time) (longestFocus int, contextSwitches int) {
+	sessions, fragments := focusSessions(s, start, now)
+	for _, f := range sessions {
+		longestFocus = max(longestFocus, f.minutes())
+	}
+	if runs := len(sessions) + fragments; runs > 1 {
+		contextSwitches = runs - 1
+	}
+	return longestFocus, contextSwitches
+}
+
+// FocusSession is a run of working time in which idle gaps shorter than the
+// configured gap were bridged.
+type FocusSession struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (f FocusSession) minutes() int { return int(f.End.Sub(f.Start).Minutes()) }
+
+// focusSettings returns the gap to merge, the minimum session length and the
+// deep-work threshold, in minutes.
+func focusSettings(cfg Config) (gap, minSession, deepWork int) {
+	gap, minSession, deepWork = cfg.FocusGapMinutes, 15, 90
+	if cfg.MinSessionMinutes > 0 {
+		minSession = cfg.MinSessionMinutes
+	}
+	if cfg.DeepWorkMinutes > 0 {
+		deepWork = cfg.DeepWorkMinutes
+	}
+	return gap, minSession, deepWork
+}
+
+// focusSessions returns the sessions between start and end, plus the number of
+// working runs too short to count as one.
+func focusSessions(s *Store, start, end time.Time) (sessions []FocusSession, fragments int) {
+	gap, minSession, _ := focusSettings(s.Config)
+	bins := fetchBins(s, start, end)
+
+	var runs []FocusSession
+	for cur := floorToBin(start); cur.Before(end); cur = cur.Add(binMinutes * time.Minute) {
+		if bins[cur] != 1 {
+			continue
+		}
+		next := cur.Add(binMinutes * time.Minute)
+		if n := len(runs); n > 0 && (cur.Equal(runs[n-1].End) || cur.Sub(runs[n-1].End) < time.Duration(gap)*time.Minute) {
+			runs[n-1].End = next
+			continue
+		}
+		runs = append(runs, FocusSession{Start: cur, End: next})
+	}
+
+	for _, r := range runs {
+		if r.minutes() >= minSession {
+			sessions = append(sessions, r)
+		} else {
+			fragments++
+		}
+	}
+	return sessions, fragments
+}
+
+type focusSummary struct {
+	Sessions      int
+	Fragments     int
+	Longest       int
+	Median        int
+	DeepWork      int     // minutes in sessions at least as long as the deep-work threshold
+	Worked        int     // working minutes in sessions and fragments
+	Fragmentation float64 // sessions and fragments per hour worked
+}
+
+func summarizeFocus(s *Store, start, end time.Time) focusSummary {
+	_, _, deepWork := focusSettings(s.Config)
+	sessions, fragments := focusSessions(s, start, end)
+	sum := focusSummary{Sessions: len(sessions), Fragments: fragments}
+
+	var lengths []int
+	for _, f := range sessions {
+		lengths = append(lengths, f.minutes())
+		sum.Longest = max(sum.Longest, f.minutes())
+		if f.minutes() >= deepWork {
+			sum.DeepWork += f.minutes()
+		}
+	}
+	if len(lengths) > 0 {
+		sort.Ints(lengths)
+		sum.Median = lengths[len(lengths)/2]
+		if len(lengths)%2 == 0 {
+			sum.Median = (lengths[len(lengths)/2-1] + lengths[len(lengths)/2]) / 2
+		}
+	}
+	for _, v := range fetchBins(s, start, end) {
+		if v == 1 {
+			sum.Worked += binMinutes
+		}
+	}
+	if sum.Worked > 0 {
+		sum.Fragmentation = float64(sum.Sessions+sum.Fragments) / (float64(sum.Worked) / 60)
+	}
+	return sum
+}
+
+// sparkline draws values as a row of block characters scaled to the largest one.
+func sparkline(values []float64) string {
+	ticks := []rune("▁▂▃▄▅▆▇█")
+	most := 0.0
+	for _, v := range values {
+		most = max(most, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if most > 0 {
+			i = int(v / most * float64(len(ticks)-1))
+		}
+		b.WriteRune(ticks[i])
+	}
+	return b.String()
+}
+
+// weeklyFocus summarizes each of the last n weeks, oldest first.
+func weeklyFocus(s *Store, n int) (weeks []time.Time, sums []focusSummary) {
+	now := time.Now()
+	for i := n - 1; i >= 0; i-- {
+		start := startOfWeek(now).AddDate(0, 0, -7*i)
+		weeks = append(weeks, start)
+		sums = append(sums, summarizeFocus(s, start, minTime(start.AddDate(0, 0, 7), now)))
+	}
+	return weeks, sums
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// focusTrends renders one sparkline per focus metric over the given weeks.
+func focusTrends(sums []focusSummary) string {
+	var median, deep, frag []float64
+	for _, f := range sums {
+		median = append(median, float64(f.Median))
+		deep = append(deep, float64(f.DeepWork))
+		frag = append(frag, f.Fragmentation)
+	}
+	last := sums[len(sums)-1]
+	return fmt.Sprintf("Median session  %s %s\nDeep work       %s %s\nFragmentation   %s %.1f/h",
+		sparkline(median), humanDuration(last.Median),
+		sparkline(deep), humanDuration(last.DeepWork),
+		sparkline(frag), last.Fragmentation)
+}
+
+func reportFocus(s *Store, start, end time.Time) {
+	gap, minSession, deepWork := focusSettings(s.Config)
+	fmt.Printf("Focus sessions, %s to %s\n", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Printf("(gaps under %d mins merged, sessions from %d mins, deep work from %d mins)\n", gap, minSession, deepWork)
+	fmt.Println(strings.Repeat("-", 78))
+	fmt.Printf("%-12s | %8s | %12s | %12s | %12s | %s\n", "Date", "Sessions", "Median", "Longest", "Deep work", "Frag/h")
+	fmt.Println(strings.Repeat("-", 78))
+	for d := start; d.Before(end) && d.Before(time.Now()); d = d.AddDate(0, 0, 1) {
+		f := summarizeFocus(s, d, d.AddDate(0, 0, 1))
+		if f.Worked == 0 {
+			continue
+		}
+		fmt.Printf("%-12s | %8d | %12s | %12s | %12s | %.1f\n", d.Format("Mon Jan 02"), f.Sessions,
+			humanDuration(f.Median), humanDuration(f.Longest), humanDuration(f.DeepWork), f.Fragmentation)
+	}
+	fmt.Println(strings.Repeat("-", 78))
+	total := summarizeFocus(s, start, end)
+	fmt.Printf("%-12s | %8d | %12s | %12s | %12s | %.1f\n", "Total", total.Sessions,
+		humanDuration(total.Median), humanDuration(total.Longest), humanDuration(total.DeepWork), total.Fragmentation)
+
+	weeks := int(end.Sub(start).Hours()/24/7 + 0.5)
+	if weeks >= 2 {
+		_, sums := weeklyFocus(s, weeks)
+		fmt.Printf("\nWeekly trend (last %d weeks, this week on the right)\n", weeks)
+		fmt.Println(focusTrends(sums))
+	}
+}
+
+func calculatePeriodProgress(s *Store) (weekHours, weekGoal, monthHours, monthGoal, yearHours, yearGoal int) {
+	now := time.Now()
+
+	weekStart := startOfWeek(now)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	// Month calculation
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	// Year calculation
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	yearEnd := yearStart.AddDate(1, 0, 0)
+
+	// Calculate week hours and goal
+	weekBins := fetchBins(s, weekStart, weekEnd)
+	for _, v := range weekBins {
+		if v == 1 {
+			weekHours += binMinutes
+		}
+	}
+	weekGoal = periodGoal(s, weekStart, weekEnd)
+
+	// Calculate month hours and goal
+	monthBins := fetchBins(s, monthStart, monthEnd)
+	for _, v := range monthBins {
+		if v == 1 {
+			monthHours += binMinutes
+		}
+	}
+	monthGoal = periodGoal(s, monthStart, monthEnd)
+
+	// Calculate year hours and goal
+	yearBins := fetchBins(s, yearStart, yearEnd)
+	for _, v := range yearBins {
+		if v == 1 {
+			yearHours += binMinutes
+		}
+	}
+	for d := yearStart; d.Before(yearEnd); d = d.AddDate(0, 1, 0) {
+		yearGoal += periodGoal(s, d, d.AddDate(0, 1, 0))
+	}
+
+	return
+}
+
+// expectedMinutes is what the day of t contributes to the goals. Days in a
+// period with an explicit weekly or monthly target get their share of it.
+func expectedMinutes(s *Store, t time.Time) int {
+	goal := dailyGoal(s, t)
+	if goal == 0 {
+		return 0
+	}
+	sch := scheduleAt(s, t)
+	if sch == nil {
+		return goal
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	var start, end time.Time
+	switch {
+	case sch.WeeklyMinutes > 0:
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		end = start.AddDate(0, 0, 7)
+	case sch.MonthlyMinutes > 0:
+		start = day.AddDate(0, 0, 1-day.Day())
+		end = start.AddDate(0, 1, 0)
+	default:
+		return goal
+	}
+	sum := 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		sum += dailyGoal(s, d)
+	}
+	return periodGoal(s, start, end) * goal / sum
+}
+
+// balanceStart is the first day of the flextime balance: the configured
+// start or else the first day with any tracked data.
+func balanceStart(s *Store) time.Time {
+	if t, err := time.ParseInLocation("2006-01-02", s.Config.BalanceStart, time.Local); err == nil {
+		return t
+	}
+	first := time.Now().Unix()
+	for k := range s.Bins {
+		if ts, err := strconv.ParseInt(k, 10, 64); err == nil && ts < first {
+			first = ts
+		}
+	}
+	for _, r := range s.Ranges {
+		if !r.TagOnly && !r.Split && r.Start < first {
+			first = r.Start
+		}
+	}
+	t := time.Unix(first, 0)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// workedByDay sums the working minutes per day ("2006-01-02") in [start, end).
+func workedByDay(s *Store, start, end time.Time) map[string]int {
+	days := map[string]int{}
+	for t, v := range fetchBins(s, start, end) {
+		if v == 1 {
+			days[t.Format("2006-01-02")] += binMinutes
+		}
+	}
+	return days
+}
+
+// calculateBalance returns the flextime balance (worked minus expected, plus
+// adjustments) contributed by today, the current week so far and all time.
+func calculateBalance(s *Store) (today, week, total int) {
+	now := time.Now()
+	todayStart := startOfDay(now)
+	weekStart := startOfWeek(now)
+	start := balanceStart(s)
+	if weekStart.Before(start) {
+		start = weekStart
+	}
+	worked := workedByDay(s, start, todayStart.AddDate(0, 0, 1))
+	counted := balanceStart(s)
+
+	for d := start; !d.After(todayStart); d = d.AddDate(0, 0, 1) {
+		delta := worked[d.Format("2006-01-02")] - expectedMinutes(s, d)
+		if !d.Before(counted) {
+			total += delta
+		}
+		if !d.Before(weekStart) {
+			week += delta
+		}
+		if d.Equal(todayStart) {
+			today = delta
+		}
+	}
+	for _, a := range s.Adjustments {
+		if a.Date <= todayStart.Format("2006-01-02") {
+			total += a.Minutes
+		}
+	}
+	return
+}
+
+func signedDuration(mins int) string {
+	if mins < 0 {
+		return "-" + humanDuration(-mins)
+	}
+	return "+" + humanDuration(mins)
+}
+
+func balanceStyle(mins int) lipgloss.Style {
+	if mins < 0 {
+		return idleStyle
+	}
+	return workingStyle
+}
+
+// refreshBalance replays the flextime history, which gets slow as the store
+// grows, so it isn't done on every render.
+func (m *dashboardModel) refreshBalance() {
+	m.balance[0], m.balance[1], m.balance[2] = calculateBalance(m.store)
+}
+
+func createBalanceBox(balance [3]int) string {
+	today, week, total := balance[0], balance[1], balance[2]
+	return fmt.Sprintf("⚖️  FLEXTIME BALANCE\n\n"+
+		"Today: %s\n"+
+		"Week: %s\n"+
+		"All time: %s",
+		balanceStyle(today).Render(signedDuration(today)),
+		balanceStyle(week).Render(signedDuration(week)),
+		balanceStyle(total).Render(signedDuration(total)))
+}
+
+func calculateTagHours(s *Store, period string) map[string]int {
+	now := time.Now()
+	var start, end time.Time
+
+	switch period {
+	case "day":
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		end = start.Add(24 * time.Hour)
+	case "week":
+		start = startOfWeek(now)
+		end = start.AddDate(0, 0, 7)
+	case "month":
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 1, 0)
+	}
+
+	return tagMinutes(s, start, end)
+}
+
+// createTagAnalyticsBox shows tag totals as a tree where parents include their
+// children; depth limits how many levels are expanded, 0 shows all.
+func createTagAnalyticsBox(s *Store, width, depth int) string {
+	content := "🏷️  TAG ANALYTICS\n\n"
+
+	dayTags := rollupTags(calculateTagHours(s, "day"))
+	weekTags := rollupTags(calculateTagHours(s, "week"))
+	monthTags := rollupTags(calculateTagHours(s, "month"))
+
+	// Collect all unique tags
+	allTagsMap := make(map[string]int)
+	for _, period := range []map[string]int{dayTags, weekTags, monthTags} {
+		for tag, mins := range period {
+			allTagsMap[tag] += mins
+		}
+	}
+
+	if len(allTagsMap) == 0 {
+		content += "No tagged time recorded"
+		return content
+	}
+
+	collapsed := func(n tagNode) bool { return depth > 0 && n.depth >= depth-1 }
+	for _, n := range visibleTagNodes(tagTree(allTagsMap), collapsed) {
+		indent := strings.Repeat("  ", n.depth)
+		marker := n.marker(collapsed(n))
+		if n.path == "(untagged)" {
+			content += fmt.Sprintf("%s%s%s\n", indent, marker, idleStyle.Render(n.name))
+		} else {
+			content += fmt.Sprintf("%s%s%s\n", indent, marker, tagStyleFor(s, n.path).Render(n.name))
+		}
+		content += fmt.Sprintf("%s  Day: %s | Week: %s | Month: %s\n\n", indent,
+			workingStyle.Render(humanDuration(dayTags[n.path])),
+			workingStyle.Render(humanDuration(weekTags[n.path])),
+			workingStyle.Render(humanDuration(monthTags[n.path])))
+	}
+
+	return content
+}
+
+// maxTagDepth returns how many levels the deepest known tag has.
+func maxTagDepth(s *Store) int {
+	depth := 1
+	for _, tag := range knownTags(s) {
+		depth = max(depth, len(tagAncestors(tag)))
+	}
+	return depth
+}
+
+// normalizeTag trims a hierarchical tag like " acme / backend/" to "acme/backend".
+func normalizeTag(tag string) string {
+	var parts []string
+	for _, p := range strings.Split(tag, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// tagMatches reports whether tag is filter or one of its descendants.
+func tagMatches(tag, filter string) bool {
+	return tag == filter || strings.HasPrefix(tag, filter+"/")
+}
+
+// tagAncestors returns tag and every parent of it, outermost first.
+func tagAncestors(tag string) []string {
+	if tag == "(untagged)" {
+		return []string{tag}
+	}
+	var out []string
+	for i, r := range tag {
+		if r == '/' {
+			out = append(out, tag[:i])
+		}
+	}
+	return append(out, tag)
+}
+
+// rollupTags adds each tag's minutes to all of its parents as well.
+func rollupTags(hours map[string]int) map[string]int {
+	out := map[string]int{}
+	for tag, mins := range hours {
+		for _, t := range tagAncestors(tag) {
+			out[t] += mins
+		}
+	}
+	return out
+}
+
+type tagNode struct {
+	path  string
+	name  string
+	depth int
+	leaf  bool
+}
+
+// tagTree orders rolled-up tags depth first, parents before their children and
+// (untagged) last.
+func tagTree(rolled map[string]int) []tagNode {
+	var paths []string
+	for p := range rolled {
+		if p != "(untagged)" {
+			paths = append(paths, p)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.ReplaceAll(paths[i], "/", "\x00") < strings.ReplaceAll(paths[j], "/", "\x00")
+	})
+	if _, ok := rolled["(untagged)"]; ok {
+		paths = append(paths, "(untagged)")
+	}
+	nodes := make([]tagNode, len(paths))
+	for i, p := range paths {
+		depth := len(tagAncestors(p)) - 1
+		nodes[i] = tagNode{path: p, name: p[strings.LastIndex(p, "/")+1:], depth: depth, leaf: true}
+		if p == "(untagged)" {
+			nodes[i].name = p
+		}
+		if i > 0 && tagMatches(p, paths[i-1]) && p != paths[i-1] {
+			nodes[i-1].leaf = false
+		}
+	}
+	return nodes
+}
+
+// visibleTagNodes drops the descendants of collapsed nodes.
+func visibleTagNodes(nodes []tagNode, collapsed func(tagNode) bool) []tagNode {
+	var out []tagNode
+	hidden := ""
+	for _, n := range nodes {
+		if hidden != "" && tagMatches(n.path, hidden) {
+			continue
+		}
+		hidden = ""
+		out = append(out, n)
+		if !n.leaf && collapsed(n) {
+			hidden = n.path
+		}
+	}
+	return out
+}
+
+func (n tagNode) marker(collapsed bool) string {
+	switch {
+	case n.leaf:
+		return "  "
+	case collapsed:
+		return "▸ "
+	}
+	return "▾ "
+}
+
+func (n tagNode) label(collapsed bool) string {
+	return strings.Repeat("  ", n.depth) + n.marker(collapsed) + n.name
+}
+
+// calculateAppHours attributes each working bin in [start, end) to the
+// application that was focused for most of it.
+func calculateAppHours(s *Store, start, end time.Time) map[string]int {
+	appHours := make(map[string]int)
+	if len(s.Windows) == 0 {
+		return appHours
+	}
+	for t, v := range fetchBins(s, start, end) {
+		if v != 1 {
+			continue
+		}
+		if w, ok := windowAt(s, t); ok {
+			appHours[w.App] += binMinutes
+		} else {
+			appHours["(unknown)"] += binMinutes
+		}
+	}
+	return appHours
+}
+
+// sortedByMinutes returns the keys of a minutes map, largest first.
+func sortedByMinutes(hours map[string]int) []string {
+	keys := make([]string, 0, len(hours))
+	for k := range hours {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if hours[keys[i]] != hours[keys[j]] {
+			return hours[keys[i]] > hours[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func createAppAnalyticsBox(s *Store, width int) string {
+	content := "🖥️  APPLICATIONS\n\n"
+
+	now := time.Now()
+	dayStart := startOfDay(now)
+	weekStart := startOfWeek(now)
+
+	dayApps := calculateAppHours(s, dayStart, dayStart.Add(24*time.Hour))
+	weekApps := calculateAppHours(s, weekStart, weekStart.AddDate(0, 0, 7))
+	if len(weekApps) == 0 {
+		content += "No application data recorded"
+		return content
+	}
+
+	apps := sortedByMinutes(weekApps)
+	if len(apps) > 5 {
+		apps = apps[:5]
+	}
+	for _, app := range apps {
+		content += fmt.Sprintf("%s\n  Day: %s | Week: %s\n",
+			tagStyle.Render(app),
+			workingStyle.Render(humanDuration(dayApps[app])),
+			workingStyle.Render(humanDuration(weekApps[app])))
+	}
+	return strings.TrimRight(content, "\n")
+}
+
+func printAppBreakdown(s *Store, start, end time.Time) {
+	apps := calculateAppHours(s, start, end)
+	if len(apps) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("%-25s | %s\n", "Application", "Working Time")
+	fmt.Println(strings.Repeat("-", 50))
+	for _, app := range sortedByMinutes(apps) {
+		fmt.Printf("%-25s | %s\n", app, humanDuration(apps[app]))
+	}
+}
+
+// runCommand dispatches subcommands given after the global flags.
+func runCommand(file string, args []string) error {
+	switch args[0] {
+	case "retag":
+		return cmdRetag(file, args[1:])
+	case "session":
+		return cmdSession(file, args[1:])
+	case "pomodoro":
+		return cmdPomodoro(file, args[1:])
+	case "hooks":
+		return cmdHooks(file, args[1:])
+	case "git-hook":
+		return cmdGitHook(file, args[1:])
+	case "git-event":
+		return cmdGitEvent(file, args[1:])
+	case "calendar":
+		return cmdCalendar(file, args[1:])
+	case "off":
+		return cmdOff(file, args[1:])
+	case "goals":
+		return cmdGoals(file, args[1:])
+	case "balance":
+		return cmdBalance(file, args[1:])
+	case "undo":
+		return cmdUndo(file, args[1:], false)
+	case "redo":
+		return cmdUndo(file, args[1:], true)
+	case "tags":
+		return cmdTags(file, args[1:])
+	case "merge":
+		return cmdMerge(args[1:])
+	case "sync":
+		return cmdSync(file, args[1:])
+	case "team":
+		return cmdTeam(args[1:])
+	case "store":
+		return cmdStore(file, args[1:])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// newCommandFlags creates a subcommand flag set that also accepts --file,
+// defaulting to the global one.
+func newCommandFlags(name, file string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, fs.String("file", file, "path to JSON store")
+}
+
+// parseArgs parses the flags in args and returns the positional arguments,
+// which may come before, between and after the flags:
+// `off add 2025-12-24 --reason xmas 2025-12-31`. Everything after -- is positional.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var words []string
+	for {
+		for len(args) > 0 && (args[0] == "-" || !strings.HasPrefix(args[0], "-")) {
+			words, args = append(words, args[0]), args[1:]
+		}
+		if len(args) == 0 {
+			return words
+		}
+		if args[0] == "--" {
+			return append(words, args[1:]...)
+		}
+		fs.Parse(args)
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(words, rest...)
+		}
+		args = rest
+	}
+}
+
+// parseDateArg accepts "2006-01-02" or "2006-01-02 15:04" in local time. With
+// endOfDay a bare date means the end of that day.
+func parseDateArg(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or \"YYYY-MM-DD HH:MM\"", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+type retagBin struct {
+	bin             time.Time
+	oldTag, oldNote string
+	newTag, newNote string
+}
+
+// planRetag evaluates the rules for every working bin in [from, to) that is
+// not tagged by hand or by another source.
+func planRetag(s *Store, rules []Rule, from, to time.Time) []retagBin {
+	var plan []retagBin
+	ix := indexBins(s, from, to)
+	for t, v := range fetchBins(s, from, to) {
+		if v != 1 {
+			continue
+		}
+		b := retagBin{bin: t}
+		if idx := ix.tagRangeAt(t); idx >= 0 {
+			r := s.Ranges[idx]
+			if !isRuleSource(r.Source) {
+				continue
+			}
+			b.oldTag, b.oldNote = joinTags(r.Tags), r.Note
+		}
+		if r := matchRules(rules, ruleContextAt(s, t)); r != nil {
+			b.newTag, b.newNote = joinTags(parseTags(r.Tag)), r.Note
+		}
+		plan = append(plan, b)
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].bin.Before(plan[j].bin) })
+	return plan
+}
+
+// printRetagPlan lists contiguous runs of bins whose tag or note would change.
+func printRetagPlan(plan []retagBin) int {
+	changed := 0
+	label := func(tag string) string {
+		if tag == "" {
+			return "(untagged)"
+		}
+		return tag
+	}
+	for i := 0; i < len(plan); {
+		b := plan[i]
+		j := i + 1
+		for j < len(plan) && plan[j].bin.Equal(plan[j-1].bin.Add(binMinutes*time.Minute)) &&
+			plan[j].oldTag == b.oldTag && plan[j].newTag == b.newTag &&
+			plan[j].oldNote == b.oldNote && plan[j].newNote == b.newNote {
+			j++
+		}
+		if b.oldTag != b.newTag || b.oldNote != b.newNote {
+			end := plan[j-1].bin.Add(binMinutes * time.Minute)
+			fmt.Printf("%s %s-%s  %s → %s\n", b.bin.Format("Mon 2006-01-02"), b.bin.Format("15:04"), end.Format("15:04"), label(b.oldTag), label(b.newTag))
+			changed += j - i
+		}
+		i = j
+	}
+	return changed
+}
+
+func cmdRetag(file string, args []string) error {
+	fs, path := newCommandFlags("retag", file)
+	fromStr := fs.String("from", "", "start date or time (default: today)")
+	toStr := fs.String("to", "", "end date or time, a bare date is inclusive (default: now)")
+	dryRun := fs.Bool("dry-run", false, "show the blocks that would change without saving")
+	fs.Parse(args)
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := now
+	var err error
+	if *fromStr != "" {
+		if from, err = parseDateArg(*fromStr, false); err != nil {
+			return err
+		}
+	}
+	if *toStr != "" {
+		if to, err = parseDateArg(*toStr, true); err != nil {
+			return err
+		}
+	}
+	from = floorToBin(from)
+	if !to.Equal(floorToBin(to)) {
+		to = nextBinStart(to)
+	}
+
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	rules, err := loadRules(rulesPath(*path), store.Config)
+	if err != nil {
+		return fmt.Errorf("load rules: %w", err)
+	}
+
+	plan := planRetag(store, rules, from, to)
+	changed := printRetagPlan(plan)
+	if changed == 0 {
+		fmt.Println("No blocks to retag")
+		return nil
+	}
+	if *dryRun {
+		fmt.Printf("Dry run: %s would be retagged\n", humanDuration(changed*binMinutes))
+		return nil
+	}
+
+	before := cloneRanges(store.Ranges)
+	cutTagRanges(store, from.Unix(), to.Unix(), func(r Range) bool { return isRuleSource(r.Source) })
+	for _, b := range plan {
+		applyAutoTag(store, b.bin, b.newTag, b.newNote, "rule")
+	}
+	recordChange(store, fmt.Sprintf("retag %s - %s", from.Format("Jan 2 15:04"), to.Format("Jan 2 15:04")), before)
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	fmt.Printf("Retagged %s\n", humanDuration(changed*binMinutes))
+	return nil
+}
+
+func cmdSession(file string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: session start NAME | stop | status")
+	}
+	fs, path := newCommandFlags("session "+args[0], file)
+	names := parseArgs(fs, args[1:])
+
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	now := time.Now()
+	active := activeSession(store)
+	var events []HookEvent
+	stopped := func() {
+		e := newHookEvent(store, "session_stop", now)
+		e.Session = active.Name
+		events = append(events, e)
+	}
+
+	switch args[0] {
+	case "start":
+		if len(names) != 1 {
+			return fmt.Errorf("usage: session start NAME")
+		}
+		if active != nil {
+			active.End = now.Unix()
+			stopped()
+		}
+		store.Sessions = append(store.Sessions, Session{Name: names[0], Start: now.Unix()})
+		events = append(events, newHookEvent(store, "session_start", now))
+		fmt.Printf("Session %q started at %s\n", names[0], now.Format("15:04"))
+	case "stop":
+		if active == nil {
+			return fmt.Errorf("no session is running")
+		}
+		active.End = now.Unix()
+		stopped()
+		fmt.Printf("Session %q stopped after %s\n", active.Name, humanDuration(int(now.Unix()-active.Start)/60))
+	case "status":
+		if active == nil {
+			fmt.Println("No session running")
+		} else {
+			fmt.Printf("Session %q running for %s\n", active.Name, humanDuration(int(now.Unix()-active.Start)/60))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown session command %q", args[0])
+	}
+
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	for _, e := range events {
+		fireHooks(store.Config.Hooks, *path, e)
+	}
+	return nil
+}
+
+// pomodoroSettings returns the work, short break and long break lengths in
+// minutes and after how many pomodoros the long break comes.
+func pomodoroSettings(cfg Config) (work, short, long, every int) {
+	work, short, long, every = 25, 5, 15, 4
+	if cfg.PomodoroMinutes > 0 {
+		work = cfg.PomodoroMinutes
+	}
+	if cfg.ShortBreakMinutes > 0 {
+		short = cfg.ShortBreakMinutes
+	}
+	if cfg.LongBreakMinutes > 0 {
+		long = cfg.LongBreakMinutes
+	}
+	if cfg.LongBreakEvery > 0 {
+		every = cfg.LongBreakEvery
+	}
+	return work, short, long, every
+}
+
+// pomodoro runs work and break phases for the pomodoro command and the
+// dashboard, and reminds about breaks after long stretches of work.
+type pomodoro struct {
+	running    bool
+	onBreak    bool
+	since      time.Time // start of the current phase
+	done       int       // completed work phases
+	tag        string    // logged instead of the tags worked on, when set
+	rested     time.Duration
+	lastSample time.Time
+	warned     time.Time // start of the focus session last warned about
+}
+
+func (p *pomodoro) start(now time.Time) {
+	*p = pomodoro{running: true, since: now, tag: p.tag, warned: p.warned}
+}
+
+func (p *pomodoro) length(cfg Config) time.Duration {
+	work, short, long, every := pomodoroSettings(cfg)
+	switch {
+	case !p.onBreak:
+		return time.Duration(work) * time.Minute
+	case p.done%every == 0:
+		return time.Duration(long) * time.Minute
+	}
+	return time.Duration(short) * time.Minute
+}
+
+// advance moves the cycle on to now; idle is how long the user has been
+// inactive, negative when unknown. A finished work phase is logged in s. It
+// returns a notification when a phase ends.
+func (p *pomodoro) advance(s *Store, now time.Time, idle time.Duration) (title, body string) {
+	if !p.running {
+		return "", ""
+	}
+	if p.onBreak && idle >= 0 && !p.lastSample.IsZero() && idle >= now.Sub(p.lastSample) {
+		p.rested += now.Sub(p.lastSample)
+	}
+	p.lastSample = now
+	end := p.since.Add(p.length(s.Config))
+	if now.Before(end) {
+		return "", ""
+	}
+
+	if !p.onBreak {
+		tags := parseTags(p.tag)
+		if len(tags) == 0 {
+			for _, tag := range sortedByMinutes(tagMinutes(s, p.since, end)) {
+				if tag != "(untagged)" {
+					tags = []string{tag}
+					break
+				}
+			}
+		}
+		s.Pomodoros = append(s.Pomodoros, Pomodoro{Start: p.since.Unix(), End: end.Unix(), Tags: tags})
+		p.done++
+		p.onBreak, p.since, p.rested = true, end, 0
+		title = fmt.Sprintf("Pomodoro #%d done", p.done)
+		if len(tags) > 0 {
+			title += " (" + joinTags(tags) + ")"
+		}
+		return title, fmt.Sprintf("Take a %s break", humanDuration(int(p.length(s.Config).Minutes())))
+	}
+
+	breakLen := p.length(s.Config)
+	if n := len(s.Pomodoros); n > 0 {
+		s.Pomodoros[n-1].Rested = int(p.rested.Minutes())
+	}
+	p.onBreak, p.since = false, end
+	body = "Back to work"
+	if idle >= 0 && p.rested < breakLen/2 {
+		body = fmt.Sprintf("You were only away for %s of the %s break", humanDuration(int(p.rested.Minutes())), humanDuration(int(breakLen.Minutes())))
+	}
+	return "Break over", body
+}
+
+// status describes the running phase, e.g. "🍅 12 mins left • 2 done".
+func (p *pomodoro) status(cfg Config, now time.Time) string {
+	if !p.running {
+		return ""
+	}
+	icon := "🍅"
+	if p.onBreak {
+		icon = "☕"
+	}
+	left := int(math.Ceil(p.since.Add(p.length(cfg)).Sub(now).Minutes()))
+	return fmt.Sprintf("%s %s left • %d done", icon, humanDuration(max(left, 0)), p.done)
+}
+
+// remind warns once per focus session that runs past break_reminder_minutes.
+func (p *pomodoro) remind(s *Store, now time.Time) (title, body string) {
+	if s.Config.BreakReminder <= 0 {
+		return "", ""
+	}
+	f, ok := currentFocus(s, now)
+	if !ok || f.minutes() < s.Config.BreakReminder || f.Start.Equal(p.warned) {
+		return "", ""
+	}
+	p.warned = f.Start
+	return "Time for a break", fmt.Sprintf("You have been working for %s without a break", humanDuration(f.minutes()))
+}
+
+// currentFocus returns the focus session still running at now, built from the
+// same bins and gap rules as the focus statistics.
+func currentFocus(s *Store, now time.Time) (FocusSession, bool) {
+	gap, _, _ := focusSettings(s.Config)
+	sessions, _ := focusSessions(s, now.Add(-24*time.Hour), now)
+	if len(sessions) == 0 {
+		return FocusSession{}, false
+	}
+	last := sessions[len(sessions)-1]
+	return last, now.Sub(last.End) < time.Duration(gap)*time.Minute
+}
+
+// idleFor returns how long the user has been inactive, or -1 without an idle source.
+func idleFor(now time.Time) time.Duration {
+	la, err := lastActivity(now)
+	if err != nil {
+		return -1
+	}
+	return now.Sub(la)
+}
+
+// notifySinks returns where notifications go: notify_sinks, otherwise the
+// command when notify_command is set, or the desktop.
+func notifySinks(cfg Config) []string {
+	switch {
+	case len(cfg.NotifySinks) > 0:
+		return cfg.NotifySinks
+	case cfg.NotifyCommand != "":
+		return []string{"command"}
+	}
+	return []string{"desktop"}
+}
+
+// notify sends a notification to every sink. The command sink runs
+// notify_command in the shell with the title and body as $1 and $2, e.g. to
+// call a webhook; the desktop sink uses notify-send (D-Bus) on Linux and
+// osascript on macOS. Commands still running after 10 seconds are killed.
+func notify(cfg Config, title, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var errs []error
+	for _, sink := range notifySinks(cfg) {
+		var err error
+		switch {
+		case sink == "none":
+		case sink == "stdout":
+			fmt.Printf("\n[notify] %s: %s\n", title, body)
+		case sink == "command" && cfg.NotifyCommand == "":
+			err = fmt.Errorf("notify_command is not set")
+		case sink == "command":
+			err = exec.CommandContext(ctx, "/bin/sh", "-c", cfg.NotifyCommand, "notify", title, body).Run()
+		case sink == "desktop" && runtime.GOOS == "darwin":
+			script := fmt.Sprintf("display notification %q with title %q", body, title)
+			err = exec.CommandContext(ctx, "osascript", "-e", script).Run()
+		case sink == "desktop" && runtime.GOOS == "linux":
+			err = exec.CommandContext(ctx, "notify-send", "--app-name=timetrackcli", title, body).Run()
+		case sink == "desktop":
+			err = fmt.Errorf("not supported on %s", runtime.GOOS)
+		default:
+			err = fmt.Errorf("unknown sink")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notify %s: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notifyCmd sends a notification without blocking the dashboard.
+func notifyCmd(cfg Config, title, body string) tea.Cmd {
+	return func() tea.Msg {
+		notify(cfg, title, body)
+		return nil
+	}
+}
+
+// inQuietHours reports whether now falls in quiet_hours, which may wrap past midnight.
+func inQuietHours(cfg Config, now time.Time) bool {
+	from, to, ok := strings.Cut(cfg.QuietHours, "-")
+	if !ok {
+		return false
+	}
+	start, err1 := parseTimeToMinutes(from)
+	end, err2 := parseTimeToMinutes(to)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	clock := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
+
+// notifier raises the tracker's notifications. Each is sent once, keyed by day
+// or week in Store.Notified so restarts don't repeat it, and held back during
+// quiet hours.
+type notifier struct {
+	idleFailures int
+	held         []heldNote
+}
+
+type heldNote struct{ key, title, body string }
+
+// send notifies unless key was already sent; during quiet hours it is kept
+// until they end, even when that is the next day. It reports whether the
+// store changed.
+func (n *notifier) send(s *Store, now time.Time, key, title, body string) bool {
+	if _, done := s.Notified[key]; done {
+		return false
+	}
+	if inQuietHours(s.Config, now) {
+		n.held = slices.DeleteFunc(n.held, func(h heldNote) bool { return h.key == key })
+		n.held = append(n.held, heldNote{key, title, body})
+		return false
+	}
+	if err := notify(s.Config, title, body); err != nil {
+		fmt.Fprintln(os.Stderr, "\n[notify]", err)
+	}
+	return markOnce(s, key, now)
+}
+
+// markOnce records key in Store.Notified, forgetting keys older than 60 days,
+// and reports whether it was new.
+func markOnce(s *Store, key string, now time.Time) bool {
+	if _, done := s.Notified[key]; done {
+		return false
+	}
+	if s.Notified == nil {
+		s.Notified = map[string]int64{}
+	}
+	for k, t := range s.Notified {
+		if now.Sub(time.Unix(t, 0)) > 60*24*time.Hour {
+			delete(s.Notified, k)
+		}
+	}
+	s.Notified[key] = now.Unix()
+	return true
+}
+
+// check runs the triggers after a sample; idleErr is the error reading the
+// idle time, if any. It reports whether the store changed.
+func (n *notifier) check(s *Store, now time.Time, idleErr error) bool {
+	day := now.Format("2006-01-02")
+	changed := false
+	if !inQuietHours(s.Config, now) {
+		held := n.held
+		n.held = nil
+		for _, h := range held {
+			changed = n.send(s, now, h.key, h.title, h.body) || changed
+		}
+	}
+	if idleErr != nil {
+		n.idleFailures++
+		limit := s.Config.IdleFailures
+		if limit <= 0 {
+			limit = 10
+		}
+		if n.idleFailures >= limit {
+			changed = n.send(s, now, "idle:"+day, "Tracking is not working",
+				fmt.Sprintf("Idle time could not be read %d times in a row: %v", n.idleFailures, idleErr)) || changed
+		}
+		return changed
+	}
+	n.idleFailures = 0
+
+	work, _ := todayTotals(s)
+	if goal := dailyGoal(s, now); goal > 0 && work >= goal {
+		changed = n.send(s, now, "goal:"+day, "Daily goal reached", fmt.Sprintf("%s worked today", humanDuration(work))) || changed
+	}
+	weekHours, weekGoal, _, _, _, _ := calculatePeriodProgress(s)
+	week := startOfWeek(now).Format("2006-01-02")
+	for _, pct := range []int{50, 100} {
+		if weekGoal > 0 && weekHours*100 >= weekGoal*pct {
+			changed = n.send(s, now, fmt.Sprintf("week%d:%s", pct, week), fmt.Sprintf("%d%% of the weekly goal", pct),
+				fmt.Sprintf("%s of %s worked this week", humanDuration(weekHours), humanDuration(weekGoal))) || changed
+		}
+	}
+	if eod, err := parseTimeToMinutes(s.Config.EndOfDay); err == nil && s.Config.EndOfDay != "" &&
+		now.Hour()*60+now.Minute() >= eod && fetchBins(s, floorToBin(now), now)[floorToBin(now)] == 1 {
+		changed = n.send(s, now, "late:"+day, "Still working",
+			fmt.Sprintf("It is past %s, did you forget to stop?", s.Config.EndOfDay)) || changed
+	}
+	return changed
+}
+
+// HookEvent is the JSON payload hooks receive.
+type HookEvent struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Tags        []string  `json:"tags,omitempty"`
+	Session     string    `json:"session,omitempty"`
+	WorkedToday int       `json:"worked_today_minutes"`
+	GoalToday   int       `json:"goal_today_minutes"`
+}
+
+var hookEvents = []string{"working", "idle", "session_start", "session_stop", "tag", "goal"}
+
+func newHookEvent(s *Store, event string, now time.Time) HookEvent {
+	work, _ := todayTotals(s)
+	return HookEvent{Event: event, Time: now, Tags: binTags(s, floorToBin(now)), Session: sessionAt(s, now),
+		WorkedToday: work, GoalToday: dailyGoal(s, now)}
+}
+
+// hooksLogPath returns the hook log that sits next to the store.
+func hooksLogPath(storePath string) string {
+	return strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".hooks.log"
+}
+
+var hooksLogMu sync.Mutex
+
+// fireHooks runs every hook for the event, retrying failures, and appends
+// the outcome of each to the hook log. The tracker calls it in a goroutine.
+func fireHooks(hooks []Hook, storePath string, e HookEvent) {
+	payload, _ := json.Marshal(e)
+	for _, h := range hooks {
+		if h.Event != e.Event && h.Event != "*" {
+			continue
+		}
+		var err error
+		attempts := 0
+		for attempts <= h.Retries {
+			if attempts > 0 {
+				time.Sleep(time.Duration(attempts) * time.Second)
+			}
+			attempts++
+			if err = runHook(h, payload); err == nil {
+				break
+			}
+		}
+		result := "ok"
+		if err != nil {
+			result = "failed: " + err.Error()
+		}
+		hooksLogMu.Lock()
+		if f, ferr := os.OpenFile(hooksLogPath(storePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644); ferr == nil {
+			fmt.Fprintf(f, "%s %s %s (%d attempt(s)) %s\n", time.Now().Format(time.RFC3339), e.Event, h.target(), attempts, result)
+			f.Close()
+		}
+		hooksLogMu.Unlock()
+	}
+}
+
+func (h Hook) target() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return h.Command
+}
+
+func (h Hook) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout) * time.Second
+	}
+	return 10 * time.Second
+}
+
+func runHook(h Hook, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout())
+	defer cancel()
+	if h.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("HTTP %s", resp.Status)
+		}
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", h.Command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.WaitDelay = time.Second // don't wait for children still holding the output
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func cmdHooks(file string, args []string) error {
+	usage := fmt.Errorf("usage: hooks add EVENT COMMAND|URL [--timeout SECS] [--retries N] | list | remove N | test EVENT | log")
+	if len(args) == 0 {
+		return usage
+	}
+	fs, path := newCommandFlags("hooks "+args[0], file)
+	timeout := fs.Int("timeout", 0, "seconds per attempt (default 10)")
+	retries := fs.Int("retries", 0, "extra attempts after a failure")
+	words := parseArgs(fs, args[1:])
+
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	validEvent := func(e string) error {
+		if e != "*" && !contains(hookEvents, e) {
+			return fmt.Errorf("unknown event %q, use %s or *", e, strings.Join(hookEvents, ", "))
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "add":
+		if len(words) != 2 {
+			return usage
+		}
+		if err := validEvent(words[0]); err != nil {
+			return err
+		}
+		h := Hook{Event: words[0], Command: words[1], Timeout: *timeout, Retries: *retries}
+		if strings.HasPrefix(words[1], "http://") || strings.HasPrefix(words[1], "https://") {
+			h.URL, h.Command = words[1], ""
+		}
+		store.Config.Hooks = append(store.Config.Hooks, h)
+		fmt.Printf("Added hook %d on %s\n", len(store.Config.Hooks), h.Event)
+	case "list":
+		if len(store.Config.Hooks) == 0 {
+			fmt.Println("No hooks configured")
+		}
+		for i, h := range store.Config.Hooks {
+			fmt.Printf("%d. %-14s %s (timeout %s, %d retries)\n", i+1, h.Event, h.target(), h.timeout(), h.Retries)
+		}
+		return nil
+	case "remove":
+		n := 0
+		if len(words) == 1 {
+			n, _ = strconv.Atoi(words[0])
+		}
+		if n < 1 || n > len(store.Config.Hooks) {
+			return fmt.Errorf("no hook %q, see hooks list", strings.Join(words, " "))
+		}
+		store.Config.Hooks = append(store.Config.Hooks[:n-1], store.Config.Hooks[n:]...)
+		fmt.Println("Removed hook", n)
+	case "test":
+		if len(words) != 1 {
+			return usage
+		}
+		if err := validEvent(words[0]); err != nil {
+			return err
+		}
+		fireHooks(store.Config.Hooks, *path, newHookEvent(store, words[0], time.Now()))
+		fmt.Println("Fired, see", hooksLogPath(*path))
+		return nil
+	case "log":
+		data, err := os.ReadFile(hooksLogPath(*path))
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Println("No hooks have run yet")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		fmt.Println(strings.Join(lines[max(len(lines)-50, 0):], "\n"))
+		return nil
+	default:
+		return usage
+	}
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	return nil
+}
+
+func cmdPomodoro(file string, args []string) error {
+	list := len(args) > 0 && args[0] == "list"
+	if list {
+		args = args[1:]
+	}
+	fs, path := newCommandFlags("pomodoro", file)
+	tag := fs.String("tag", "", "log pomodoros against this tag instead of the tags worked on")
+	cycles := fs.Int("cycles", 0, "stop after this many pomodoros, 0 runs until interrupted")
+	rng := fs.String("range", "today", "with list: today|week|month|year or Nd")
+	fs.Parse(args)
+
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	if list {
+		start, end, ok := rangeBounds(*rng, time.Now())
+		if !ok {
+			return fmt.Errorf("unknown range %q", *rng)
+		}
+		n := 0
+		for _, p := range store.Pomodoros {
+			t := time.Unix(p.Start, 0)
+			if t.Before(start) || !t.Before(end) {
+				continue
+			}
+			tags := joinTags(p.Tags)
+			if tags == "" {
+				tags = "(untagged)"
+			}
+			fmt.Printf("%s %s-%s  %-25s rested %s\n", t.Format("Mon 2006-01-02"), t.Format("15:04"), time.Unix(p.End, 0).Format("15:04"), tags, humanDuration(p.Rested))
+			n++
+		}
+		fmt.Printf("%d pomodoro(s)\n", n)
+		return nil
+	}
+
+	work, short, long, every := pomodoroSettings(store.Config)
+	fmt.Printf("[pomodoro] %s work, %s breaks, %s every %d. Ctrl+C to stop.\n",
+		humanDuration(work), humanDuration(short), humanDuration(long), every)
+	p := pomodoro{tag: *tag}
+	p.start(time.Now())
+	show := func(title, body string) {
+		fmt.Printf("\n[pomodoro] %s: %s\n", title, body)
+		if err := notify(store.Config, title, body); err != nil {
+			fmt.Fprintln(os.Stderr, "[pomodoro] notify:", err)
+		}
+	}
+	for {
+		now := time.Now()
+		// reload so the tracker's bins are seen and its writes are kept
+		if fresh, err := loadStore(*path); err == nil {
+			store = fresh
+		}
+		if title, body := p.advance(store, now, idleFor(now)); title != "" {
+			if err := saveStore(*path, store); err != nil {
+				return fmt.Errorf("save store: %w", err)
+			}
+			show(title, body)
+			if *cycles > 0 && p.done >= *cycles && p.onBreak {
+				return nil
+			}
+		}
+		if title, body := p.remind(store, now); title != "" {
+			show(title, body)
+		}
+		fmt.Printf("[pomodoro] %s   \r", p.status(store.Config, now))
+		time.Sleep(5 * time.Second)
+	}
+}
+
+const gitHookMarker = "# timetrackcli"
+
+// gitHookCreated marks hook files that install created, so uninstall may delete them.
+const gitHookCreated = "# created by git-hook install " + gitHookMarker
+
+var shellExit = regexp.MustCompile(`^\s*exit(\s|$)`)
+
+var ticketRe = regexp.MustCompile(`[A-Z][A-Z0-9]+-[0-9]+`)
+
+// deviceName is the machine a store's own data is attributed to in merges.
+// Stores read from elsewhere get a name from fileDevice first, so the local
+// host name only ever stands for this machine.
+func deviceName(s *Store) string {
+	if s.Config.Device != "" {
+		return s.Config.Device
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		return h
+	}
+	return "unknown"
+}
+
+// fileDevice names a store read from file after the file when it doesn't
+// name its device itself.
+func fileDevice(s *Store, file string) {
+	if s.Config.Device == "" {
+		s.Config.Device = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+}
+
+func rangeDevice(s *Store, r Range) string {
+	if r.Device == "" {
+		return deviceName(s)
+	}
+	return r.Device
+}
+
+// storeDevices lists the store's own device first, then the merged ones.
+func storeDevices(s *Store) []string {
+	var others []string
+	for _, r := range s.Ranges {
+		if d := rangeDevice(s, r); d != deviceName(s) && !contains(others, d) {
+			others = append(others, d)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{deviceName(s)}, others...)
+}
+
+// filterDevice keeps only the time tracked on device. Tags and overrides
+// annotate whichever machine the time came from, so they all stay.
+func filterDevice(s *Store, device string) (*Store, error) {
+	if devices := storeDevices(s); !contains(devices, device) {
+		return nil, fmt.Errorf("unknown device %q, the store has %s", device, strings.Join(devices, ", "))
+	}
+	out := *s
+	out.Ranges = nil
+	for _, r := range s.Ranges {
+		if r.TagOnly || r.Override || rangeDevice(s, r) == device {
+			out.Ranges = append(out.Ranges, r)
+		}
+	}
+	if device != deviceName(s) {
+		out.Bins = map[string]int{}
+	}
+	return &out, nil
+}
+
+// removedKey names an item in Store.Removed: a day off by date, a meeting by
+// calendar, UID and start, tag settings and known tags by name.
+func removedKey(kind, key string) string { return kind + ":" + key }
+
+func meetingKey(mt Meeting) string { return fmt.Sprintf("%s %s@%d", mt.Calendar, mt.UID, mt.Start) }
+
+// markRemoved records that an item was deleted, so merging with a machine
+// that still has it doesn't bring it back.
+func markRemoved(s *Store, kind, key string, now time.Time) {
+	if s.Removed == nil {
+		s.Removed = map[string]int64{}
+	}
+	s.Removed[removedKey(kind, key)] = now.Unix()
+}
+
+// unionByKey appends the items of src that dst doesn't hold yet.
+func unionByKey[T any](dst, src []T) []T {
+	return mergeByKey(dst, src, func(v T) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}, func(a, b T) bool { return false })
+}
+
+// mergeByKey merges src into dst by identity: of two items with the same key
+// the one newer reports as more recent replaces the other.
+func mergeByKey[T any](dst, src []T, key func(T) string, newer func(a, b T) bool) []T {
+	at := map[string]int{}
+	for i, v := range dst {
+		at[key(v)] = i
+	}
+	for _, v := range src {
+		k := key(v)
+		if i, ok := at[k]; !ok {
+			at[k] = len(dst)
+			dst = append(dst, v)
+		} else if newer(v, dst[i]) {
+			dst[i] = v
+		}
+	}
+	return dst
+}
+
+// latestEdits resolves overlapping tag or override ranges bin by bin: manual
+// tags beat automatic ones, then the latest edit wins. Cleared spans take
+// part as empty ranges and leave nothing behind where they win.
+func latestEdits(ranges []Range) []Range {
+	better := func(a, b Range) bool {
+		if (a.Source == "") != (b.Source == "") {
+			return a.Source == ""
+		}
+		if a.Modified != b.Modified {
+			return a.Modified > b.Modified
+		}
+		return a.Device < b.Device
+	}
+	const step = binMinutes * 60
+	winner := map[int64]int{}
+	for i, r := range ranges {
+		for t := r.Start; t < r.End; t += step {
+			if j, ok := winner[t]; !ok || better(r, ranges[j]) {
+				winner[t] = i
+			}
+		}
+	}
+	times := slices.Sorted(maps.Keys(winner))
+
+	var out []Range
+	for i := 0; i < len(times); {
+		j := i
+		for j+1 < len(times) && times[j+1] == times[j]+step && winner[times[j+1]] == winner[times[i]] {
+			j++
+		}
+		r := ranges[winner[times[i]]]
+		r.Start, r.End = max(r.Start, times[i]), min(r.End, times[j]+step)
+		if len(r.Tags) > 0 || r.Note != "" || r.Override {
+			out = append(out, r)
+		}
+		i = j + 1
+	}
+	return out
+}
+
+// mergeStores combines stores of several machines into one with the first
+// store's config. Status ranges of the other stores keep their device, so a
+// bin counts as working if any machine worked in it; for tags, notes and
+// status overrides the latest edit wins, as it does for days off and
+// meetings. A session stopped anywhere is stopped.
+func mergeStores(stores []*Store) *Store {
+	out := &Store{Bins: map[string]int{}, Config: stores[0].Config, TagMeta: map[string]TagMeta{}}
+	out.Config.Device = deviceName(stores[0])
+	var status, tags, overrides []Range
+	for i, in := range stores {
+		st := *in
+		st.Ranges = slices.Clone(in.Ranges)
+		flushBins(&st)
+		for _, r := range st.Ranges {
+			if i > 0 && r.Device == "" {
+				r.Device = deviceName(in)
+			}
+			switch {
+			case r.TagOnly:
+				tags = append(tags, r)
+			case r.Override:
+				overrides = append(overrides, r)
+			default:
+				status = append(status, r)
+			}
+		}
+		if i == 0 {
+			out.Windows = st.Windows
+		}
+		tags = append(tags, st.Cleared...)
+		out.Cleared = unionByKey(out.Cleared, st.Cleared)
+		out.Sessions = mergeByKey(out.Sessions, st.Sessions, func(se Session) string {
+			return fmt.Sprintf("%s@%d", se.Name, se.Start)
+		}, func(a, b Session) bool { return b.End == 0 && a.End != 0 })
+		out.GitEvents = unionByKey(out.GitEvents, st.GitEvents)
+		out.Meetings = mergeByKey(out.Meetings, st.Meetings, meetingKey,
+			func(a, b Meeting) bool { return a.Modified > b.Modified })
+		out.DaysOff = mergeByKey(out.DaysOff, st.DaysOff, func(off DayOff) string { return off.Date },
+			func(a, b DayOff) bool { return a.Modified > b.Modified })
+		out.Adjustments = unionByKey(out.Adjustments, st.Adjustments)
+		out.Pomodoros = unionByKey(out.Pomodoros, st.Pomodoros)
+		out.Tags = unionByKey(out.Tags, st.Tags)
+		for tag, meta := range st.TagMeta {
+			if old, ok := out.TagMeta[tag]; !ok || meta.Modified > old.Modified {
+				out.TagMeta[tag] = meta
+			}
+		}
+		for k, t := range st.Removed {
+			if out.Removed == nil {
+				out.Removed = map[string]int64{}
+			}
+			out.Removed[k] = max(out.Removed[k], t)
+		}
+	}
+	// Drop what was deleted on some machine after it was last set elsewhere.
+	gone := func(kind, key string, modified int64) bool {
+		t, ok := out.Removed[removedKey(kind, key)]
+		return ok && t >= modified
+	}
+	out.DaysOff = slices.DeleteFunc(out.DaysOff, func(off DayOff) bool { return gone("dayoff", off.Date, off.Modified) })
+	out.Meetings = slices.DeleteFunc(out.Meetings, func(mt Meeting) bool { return gone("meeting", meetingKey(mt), mt.Modified) })
+	for tag, meta := range out.TagMeta {
+		if gone("tagmeta", tag, meta.Modified) {
+			delete(out.TagMeta, tag)
+		}
+	}
+	sort.Strings(out.Tags)
+	sort.Slice(out.DaysOff, func(i, j int) bool { return out.DaysOff[i].Date < out.DaysOff[j].Date })
+	sort.Slice(out.Meetings, func(i, j int) bool { return out.Meetings[i].Start < out.Meetings[j].Start })
+
+	status = unionByKey(nil, status)
+	sort.SliceStable(status, func(i, j int) bool {
+		if status[i].Device != status[j].Device {
+			return status[i].Device < status[j].Device
+		}
+		return status[i].Start < status[j].Start
+	})
+	out.Ranges = append(status, latestEdits(tags)...)
+	out.Ranges = append(out.Ranges, latestEdits(overrides)...)
+	// Known tags carry no edit time: a deleted one stays gone unless still in use.
+	used := knownTags(&Store{Ranges: out.Ranges})
+	out.Tags = slices.DeleteFunc(out.Tags, func(tag string) bool { return gone("tag", tag, 0) && !contains(used, tag) })
+	return out
+}
+
+// syncStore exchanges data through a folder shared between machines: it
+// writes this device's own data to DIR/<device>.json and merges the files of
+// all other devices into the store. An encrypted store stays encrypted in DIR
+// too, and encrypted files of other devices are only merged into a plain
+// store with plaintext set. It returns the devices synced with.
+func syncStore(path, dir string, plaintext bool) ([]string, error) {
+	s, err := loadStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("load store: %w", err)
+	}
+	header, err := readStoreHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	dev := deviceName(s)
+	own := *s
+	own.Config.Device = dev
+	own.Ranges, own.History, own.Notified = nil, nil, nil
+	for _, r := range s.Ranges {
+		if rangeDevice(s, r) == dev {
+			own.Ranges = append(own.Ranges, r)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ownFile := filepath.Join(dir, dev+".json")
+	if header != nil {
+		err = writeStore(ownFile, &own, header)
+	} else {
+		err = saveStore(ownFile, &own)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the bins live: the tracker is still filling the current one.
+	own.Bins = nil
+	stores := []*Store{&own}
+	var devices []string
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, f := range files {
+		if filepath.Base(f) == dev+".json" {
+			continue
+		}
+		if h, err := readStoreHeader(f); err != nil {
+			return nil, err
+		} else if h != nil && header == nil && !plaintext {
+			return nil, fmt.Errorf("%s is encrypted but %s is not, encrypt the store or sync with --plaintext", f, path)
+		}
+		other, err := loadStore(f)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", f, err)
+		}
+		fileDevice(other, f)
+		stores = append(stores, other)
+		devices = append(devices, deviceName(other))
+	}
+	merged := mergeStores(stores)
+	merged.Bins, merged.History, merged.Notified = s.Bins, s.History, s.Notified
+	return devices, saveStore(path, merged)
+}
+
+func cmdMerge(args []string) error {
+	usage := fmt.Errorf("usage: merge A.json B.json... -o OUT.json [--plaintext]")
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := fs.String("o", "", "file to write the merged store to")
+	plaintext := fs.Bool("plaintext", false, "write plain JSON even when an input is encrypted")
+	inputs := parseArgs(fs, args)
+	if len(inputs) < 2 || *out == "" {
+		return usage
+	}
+
+	var stores []*Store
+	var devices []string
+	var header []byte // the output takes the key of the first encrypted input
+	for _, in := range inputs {
+		if _, err := os.Stat(in); err != nil {
+			return err
+		}
+		h, err := readStoreHeader(in)
+		if err != nil {
+			return err
+		}
+		if header == nil && !*plaintext {
+			header = h
+		}
+		s, err := loadStore(in)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", in, err)
+		}
+		fileDevice(s, in)
+		stores = append(stores, s)
+		devices = append(devices, deviceName(s))
+	}
+	merged := mergeStores(stores)
+	if err := writeStore(*out, merged, header); err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d stores (%s) into %s\n", len(stores), strings.Join(devices, ", "), *out)
+	return nil
+}
+
+func cmdSync(file string, args []string) error {
+	fs, path := newCommandFlags("sync", file)
+	dir := fs.String("dir", "", "shared folder, default the syncdir config")
+	plaintext := fs.Bool("plaintext", false, "merge encrypted files of other devices into a plain store")
+	fs.Parse(args)
+	if *dir == "" {
+		s, err := loadStore(*path)
+		if err != nil {
+			return fmt.Errorf("load store: %w", err)
+		}
+		*dir = s.Config.SyncDir
+	}
+	if *dir == "" {
+		return fmt.Errorf("usage: sync --dir DIR, or set --config syncdir=DIR")
+	}
+	devices, err := syncStore(*path, *dir, *plaintext)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Printf("Wrote %s, no other devices yet\n", *dir)
+		return nil
+	}
+	fmt.Printf("Synced with %s via %s\n", strings.Join(devices, ", "), *dir)
+	return nil
+}
+
+func cmdStore(file string, args []string) error {
+	usage := fmt.Errorf("usage: store encrypt|rekey [--keyfile FILE] [--keyring] | store decrypt")
+	if len(args) == 0 {
+		return usage
+	}
+	fs, path := newCommandFlags("store "+args[0], file)
+	keyFile := fs.String("keyfile", "", "use a key file instead of a passphrase, created if missing")
+	keyring := fs.Bool("keyring", false, "save the passphrase in the OS keyring")
+	fs.Parse(args[1:])
+
+	if _, err := os.Stat(*path); err != nil {
+		return err
+	}
+	header, err := readStoreHeader(*path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "encrypt" && header != nil:
+		return fmt.Errorf("%s is already encrypted, use store rekey to change the key", *path)
+	case (args[0] == "decrypt" || args[0] == "rekey") && header == nil:
+		return fmt.Errorf("%s is not encrypted", *path)
+	case args[0] != "encrypt" && args[0] != "decrypt" && args[0] != "rekey":
+		return usage
+	}
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	if args[0] == "decrypt" {
+		if err := writeStore(*path, store, nil); err != nil {
+			return err
+		}
+		keyringDelete(*path)
+		fmt.Printf("Decrypted %s\n", *path)
+		return nil
+	}
+
+	kind, secret := byte('p'), []byte(nil)
+	if *keyFile != "" {
+		kind = 'k'
+		if secret, err = os.ReadFile(*keyFile); errors.Is(err, os.ErrNotExist) {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return err
+			}
+			if err := os.WriteFile(*keyFile, secret, 0600); err != nil {
+				return err
+			}
+			fmt.Printf("Created key file %s, keep a copy somewhere safe\n", *keyFile)
+		} else if err != nil {
+			return err
+		}
+	} else {
+		// For rekey TIMETRACK_PASSPHRASE may still hold the old one.
+		env := "TIMETRACK_PASSPHRASE"
+		if args[0] == "rekey" {
+			env = "TIMETRACK_NEW_PASSPHRASE"
+		}
+		if secret, err = newPassphrase(env); err != nil {
+			return err
+		}
+	}
+	if header, err = newStoreKey(kind, secret); err != nil {
+		return err
+	}
+	if err := writeStore(*path, store, header); err != nil {
+		return err
+	}
+	// A passphrase saved before must not outlive the key it belongs to.
+	_, err = keyringGet(*path)
+	inKeyring := err == nil
+	if kind == 'p' && (*keyring || inKeyring) {
+		if err := keyringSet(*path, secret); err != nil {
+			return err
+		}
+	} else if inKeyring {
+		keyringDelete(*path)
+	}
+	if args[0] == "rekey" {
+		fmt.Printf("Changed the key of %s\n", *path)
+	} else {
+		fmt.Printf("Encrypted %s\n", *path)
+	}
+	return nil
+}
+
+// ticketID extracts an issue key such as ABC-123 from a branch name.
+func ticketID(branch string) string {
+	return ticketRe.FindString(branch)
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func gitWindow(cfg Config) time.Duration {
+	if cfg.GitWindowMinutes > 0 {
+		return time.Duration(cfg.GitWindowMinutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+func gitTag(cfg Config, e GitEvent) string {
+	if cfg.GitTagBy == "branch" && e.Branch != "" {
+		return e.Repo + "/" + e.Branch
+	}
+	return e.Repo
+}
+
+// gitEventNear returns the git event closest to t within the git window.
+func gitEventNear(s *Store, t time.Time) *GitEvent {
+	window := gitWindow(s.Config)
+	var best *GitEvent
+	var bestDist time.Duration
+	for i := range s.GitEvents {
+		d := t.Sub(time.Unix(s.GitEvents[i].Time, 0))
+		if d < 0 && s.GitEvents[i].Kind == "checkout" {
+			continue // a checkout starts work on a branch, it says nothing about the time before
+		}
+		if d = d.Abs(); d <= window && (best == nil || d < bestDist) {
+			best, bestDist = &s.GitEvents[i], d
+		}
+	}
+	return best
+}
+
+// repoAt attributes the bin at t to a repository, preferring nearby git
+// events over the repository the focused window was working in.
+func repoAt(s *Store, t time.Time) string {
+	if e := gitEventNear(s, t.Add(binMinutes*time.Minute/2)); e != nil {
+		return e.Repo
+	}
+	if w, ok := windowAt(s, t); ok {
+		return w.Repo
+	}
+	return ""
+}
+
+// recordGitEvent stores e and tags the untagged working bins leading up to a
+// commit, or following a checkout.
+func recordGitEvent(s *Store, e GitEvent) {
+	s.GitEvents = append(s.GitEvents, e)
+	t := time.Unix(e.Time, 0)
+	from, to := t.Add(-gitWindow(s.Config)), t
+	if e.Kind == "checkout" {
+		from, to = t, t.Add(gitWindow(s.Config))
+	}
+	bins := fetchBins(s, floorToBin(from), nextBinStart(to))
+	var seq []time.Time
+	for b, v := range bins {
+		if v == 1 {
+			seq = append(seq, b)
 		}
+	}
+	sort.Slice(seq, func(i, j int) bool { return seq[i].Before(seq[j]) })
+	for _, b := range seq {
+		applyAutoTag(s, b, gitTag(s.Config, e), ticketID(e.Branch), "git")
+	}
+}
 
-		// Format working hours
-		hoursStr := humanDuration(workMins)
-		if workMins == 0 {
-			hoursStr = "No work"
+func cmdGitHook(file string, args []string) error {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		return fmt.Errorf("usage: git-hook install|uninstall [--repo DIR]")
+	}
+	fs, path := newCommandFlags("git-hook "+args[0], file)
+	repo := fs.String("repo", ".", "repository to install the hooks into")
+	fs.Parse(args[1:])
+
+	hooksDir, err := gitOutput(*repo, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return fmt.Errorf("%s is not a git repository", *repo)
+	}
+	execPath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	storePath, err := filepath.Abs(*path)
+	if err != nil {
+		return err
+	}
+	report := shellQuote(execPath) + " --file " + shellQuote(storePath) + " git-event"
+	hooks := map[string]string{
+		"post-commit":   report + " commit >/dev/null 2>&1 || true " + gitHookMarker,
+		"post-checkout": `[ "$3" = 1 ] && ` + report + " checkout >/dev/null 2>&1 || true " + gitHookMarker,
+	}
+
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+	for name, line := range hooks {
+		hookPath := filepath.Join(hooksDir, name)
+		existing, err := os.ReadFile(hookPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if errors.Is(err, os.ErrNotExist) && args[0] == "uninstall" {
+			continue
+		}
+		// Keep whatever else the hook does verbatim and only touch our own lines.
+		var kept []string
+		created := false
+		if len(existing) > 0 {
+			for _, l := range strings.Split(strings.TrimSuffix(string(existing), "\n"), "\n") {
+				if strings.HasSuffix(l, gitHookMarker) {
+					created = created || l == gitHookCreated
+					continue
+				}
+				kept = append(kept, l)
+			}
+		}
+		if args[0] == "uninstall" {
+			if created && strings.TrimSpace(strings.Join(kept, "\n")) == "#!/bin/sh" {
+				if err := os.Remove(hookPath); err != nil {
+					return err
+				}
+			} else if err := os.WriteFile(hookPath, []byte(strings.Join(kept, "\n")+"\n"), 0755); err != nil {
+				return err
+			}
+			continue
 		}
+		if len(existing) == 0 {
+			kept = []string{"#!/bin/sh", gitHookCreated}
+		}
+		// A line after a final exit would never run.
+		i := len(kept)
+		for i > 0 && strings.TrimSpace(kept[i-1]) == "" {
+			i--
+		}
+		if i > 0 && shellExit.MatchString(kept[i-1]) {
+			i--
+		}
+		kept = slices.Insert(kept, i, line)
+		if err := os.WriteFile(hookPath, []byte(strings.Join(kept, "\n")+"\n"), 0755); err != nil {
+			return err
+		}
+	}
+	if args[0] == "uninstall" {
+		fmt.Println("Removed git hooks from", hooksDir)
+	} else {
+		fmt.Println("Installed post-commit and post-checkout hooks in", hooksDir)
+	}
+	return nil
+}
 
-		content += fmt.Sprintf("%s %s %s: %s\n",
-			indicator,
-			dayName,
-			dateStr,
-			dayStyle.Render(hoursStr))
+// cmdGitEvent is invoked by the hooks from inside the repository.
+func cmdGitEvent(file string, args []string) error {
+	if len(args) == 0 || (args[0] != "commit" && args[0] != "checkout") {
+		return fmt.Errorf("usage: git-event commit|checkout")
 	}
+	fs, path := newCommandFlags("git-event", file)
+	fs.Parse(args[1:])
 
-	content += fmt.Sprintf("\nTotal: %s", workingStyle.Render(humanDuration(totalWeekHours)))
+	top, err := gitOutput(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return fmt.Errorf("not inside a git repository")
+	}
+	e := GitEvent{Time: time.Now().Unix(), Kind: args[0], Repo: filepath.Base(top)}
+	e.Branch, _ = gitOutput(top, "rev-parse", "--abbrev-ref", "HEAD")
+	e.Commit, _ = gitOutput(top, "rev-parse", "--short", "HEAD")
+	if e.Kind == "commit" {
+		if ct, err := gitOutput(top, "log", "-1", "--format=%ct"); err == nil {
+			if ts, err := strconv.ParseInt(ct, 10, 64); err == nil {
+				e.Time = ts
+			}
+		}
+	}
 
-	return content
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	recordGitEvent(store, e)
+	return saveStore(*path, store)
 }
 
-// Daily aggregate table used for week/month ranges
-func reportAggregateDaily(s *Store, start time.Time, days int, title string) {
-	fmt.Println(title)
+func reportByRepo(s *Store, start, end time.Time) {
+	fmt.Printf("by repository, %s to %s\n", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
 	fmt.Println(strings.Repeat("-", 50))
-	fmt.Printf("%-15s | %s\n", "Date", "Working Time")
+	fmt.Printf("%-25s | %s\n", "Repository", "Working Time")
 	fmt.Println(strings.Repeat("-", 50))
+
+	bins := fetchBins(s, start, end)
+	repos := map[string]int{}
+	for t, v := range bins {
+		if v != 1 {
+			continue
+		}
+		repo := repoAt(s, t)
+		if repo == "" {
+			repo = "(no repository)"
+		}
+		repos[repo] += binMinutes
+	}
 	total := 0
-	for i := 0; i < days; i++ {
-		d := start.AddDate(0, 0, i)
-		dayStart := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		bins := fetchBins(s, dayStart, dayEnd)
-		mins := 0
-		for _, v := range bins {
-			if v == 1 {
-				mins += binMinutes
-			}
+	for _, repo := range sortedByMinutes(repos) {
+		fmt.Printf("%-25s | %s\n", repo, humanDuration(repos[repo]))
+		total += repos[repo]
+	}
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Total working : %s\n", humanDuration(total))
+
+	// Commits made while the tracker saw no work point at untracked time.
+	var missed []GitEvent
+	for _, e := range s.GitEvents {
+		t := time.Unix(e.Time, 0)
+		if e.Kind == "commit" && !t.Before(start) && t.Before(end) && bins[floorToBin(t)] != 1 {
+			missed = append(missed, e)
+		}
+	}
+	if len(missed) > 0 {
+		fmt.Println()
+		fmt.Println("Commits outside working time (possibly missed time):")
+		for _, e := range missed {
+			fmt.Printf("  %s  %s (%s) %s\n", time.Unix(e.Time, 0).Format("2006-01-02 15:04"), e.Repo, e.Branch, e.Commit)
 		}
-		total += mins
-		fmt.Printf("%-15s | %s\n", d.Format("2006-01-02"), humanDuration(mins))
 	}
+}
+
+// reportByTag prints the working time matching a tag query (a tag includes its
+// subtags), per day and broken down by the tags on that time.
+func reportByTag(s *Store, start, end time.Time, query string) {
+	fmt.Printf("tag %s, %s to %s\n", query, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
 	fmt.Println(strings.Repeat("-", 50))
-	lower := strings.ToLower(title)
-	noun := "range"
-	if strings.Contains(lower, "week") {
-		noun = "week"
+	fmt.Printf("%-25s | %s\n", "Date", "Working Time")
+	fmt.Println(strings.Repeat("-", 50))
+
+	q := parseTagQuery(query)
+	days := map[string]float64{}
+	tags := map[string]float64{}
+	ix := indexBins(s, start, end)
+	for t, v := range fetchBins(s, start, end) {
+		bt := ix.binTags(t)
+		if v != 1 || !q.match(bt) {
+			continue
+		}
+		days[t.Format("2006-01-02")] += q.credit(s.Config, bt)
+		for tag, mins := range tagShares(s.Config, bt) {
+			tags[tag] += mins
+		}
 	}
-	if strings.Contains(lower, "month") {
-		noun = "month"
+	total := 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if mins := roundMinutes(days)[d.Format("2006-01-02")]; mins > 0 {
+			fmt.Printf("%-25s | %s\n", d.Format("Mon 2006-01-02"), humanDuration(mins))
+			total += mins
+		}
 	}
-	fmt.Printf("Total working %s : %s\n", noun, humanDuration(total))
-	workDaysInRange := 0
-	for i := 0; i < days; i++ {
-		if isWorkDay(start.AddDate(0, 0, i), s.Config.WorkDays) {
-			workDaysInRange++
+	fmt.Println(strings.Repeat("-", 50))
+	fmt.Printf("Total working : %s\n", humanDuration(total))
+	if total == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Breakdown:")
+	rolled := rollupTags(roundMinutes(tags))
+	for _, n := range tagTree(rolled) {
+		fmt.Printf("  %-30s %s\n", strings.Repeat("  ", n.depth)+n.name, humanDuration(rolled[n.path]))
+	}
+}
+
+// teamMember sums up one store of a team report.
+type teamMember struct {
+	name         string
+	worked, goal int
+	tags         map[string]int // rolled up minutes per tag
+}
+
+func (m teamMember) attainment() string {
+	if m.goal == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", m.worked*100/m.goal)
+}
+
+// cmdTeam reports on the stores of a whole team, one file per person named
+// after them, e.g. alice.json. Each person is measured against their own goals.
+func cmdTeam(args []string) error {
+	usage := fmt.Errorf("usage: team report --dir DIR [--range last-week] [--anonymize] | team report FILE...")
+	if len(args) == 0 || args[0] != "report" {
+		return usage
+	}
+	fs := flag.NewFlagSet("team report", flag.ExitOnError)
+	dir := fs.String("dir", "", "folder with one store per person")
+	rng := fs.String("range", "last-week", "today|week|month|year|last-week|last-month or Nd")
+	anonymize := fs.Bool("anonymize", false, "hide names and per-person tag details")
+	files := parseArgs(fs, args[1:])
+	if *dir != "" {
+		found, _ := filepath.Glob(filepath.Join(*dir, "*.json"))
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return usage
+	}
+	now := time.Now()
+	start, end, ok := rangeBounds(*rng, now)
+	if !ok {
+		return fmt.Errorf("unknown range %q", *rng)
+	}
+
+	var members []teamMember
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			return err
+		}
+		s, err := loadStore(f)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", f, err)
+		}
+		m := teamMember{
+			name: strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)),
+			goal: periodGoal(s, start, end),
+			tags: map[string]int{},
+		}
+		// Worked time and tags both stop at now, so the shares add up.
+		if until := end; start.Before(now) {
+			if until.After(now) {
+				until = now
+			}
+			m.worked, _ = dayTotals(s, start, until)
+			m.tags = rollupTags(tagMinutes(s, start, until))
 		}
+		members = append(members, m)
 	}
-	if workDaysInRange > 0 {
-		expectedMins := workDaysInRange * s.Config.DailyGoalMinutes
-		fmt.Printf("Goal progress: %s\n", formatPercentage(total, expectedMins))
+	if *anonymize {
+		// Order by attainment so the rows can't be matched to the file list.
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].worked*max(members[j].goal, 1) > members[j].worked*max(members[i].goal, 1)
+		})
+		for i := range members {
+			members[i].name = fmt.Sprintf("member %d", i+1)
+		}
+	}
+	printTeamReport(members, start, end, *anonymize)
+	return nil
+}
+
+func printTeamReport(members []teamMember, start, end time.Time, anonymize bool) {
+	fmt.Printf("Team report, %s to %s (%d people)\n", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"), len(members))
+	fmt.Println(strings.Repeat("-", 62))
+	fmt.Printf("%-20s | %14s | %14s | %s\n", "Person", "Worked", "Goal", "Attained")
+	fmt.Println(strings.Repeat("-", 62))
+	team := teamMember{name: "Team", tags: map[string]int{}}
+	for _, m := range members {
+		fmt.Printf("%-20s | %14s | %14s | %8s\n", m.name, humanDuration(m.worked), humanDuration(m.goal), m.attainment())
+		team.worked += m.worked
+		team.goal += m.goal
+		for tag, mins := range m.tags {
+			team.tags[tag] += mins
+		}
+	}
+	fmt.Println(strings.Repeat("-", 62))
+	fmt.Printf("%-20s | %14s | %14s | %8s\n", team.name, humanDuration(team.worked), humanDuration(team.goal), team.attainment())
+	if len(team.tags) == 0 {
+		return
 	}
 
+	hours := func(mins int) string {
+		if mins == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1fh", float64(mins)/60)
+	}
+	fmt.Println()
+	fmt.Printf("%-30s %8s", "Tag", "Total")
+	if anonymize {
+		fmt.Printf(" %8s", "People")
+	} else {
+		for _, m := range members {
+			fmt.Printf(" %*s", max(len(m.name), 7), m.name)
+		}
+	}
+	fmt.Println()
+	for _, n := range tagTree(team.tags) {
+		fmt.Printf("%-30s %8s", strings.Repeat("  ", n.depth)+n.name, hours(team.tags[n.path]))
+		people := 0
+		for _, m := range members {
+			if m.tags[n.path] > 0 {
+				people++
+			}
+			if !anonymize {
+				fmt.Printf(" %*s", max(len(m.name), 7), hours(m.tags[n.path]))
+			}
+		}
+		if anonymize {
+			fmt.Printf(" %8d", people)
+		}
+		fmt.Println()
+	}
 }
 
-// Year report: monthly totals
-func reportYearMonthly(s *Store, year int) {
-	loc := time.Now().Location()
-	fmt.Printf("for year %d (monthly totals)\n", year)
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Printf("%-15s | %s\n", "Month", "Working Time")
-	fmt.Println(strings.Repeat("-", 50))
-	total := 0
-	for m := time.January; m <= time.December; m++ {
-		start := time.Date(year, m, 1, 0, 0, 0, 0, loc)
-		next := start.AddDate(0, 1, 0)
-		bins := fetchBins(s, start, next)
-		mins := 0
-		for _, v := range bins {
-			if v == 1 {
-				mins += binMinutes
+// icsEvent is a VEVENT reduced to what meeting import needs.
+type icsEvent struct {
+	UID          string
+	Summary      string
+	Status       string
+	Transparent  bool
+	Start, End   time.Time
+	AllDay       bool
+	RRule        map[string]string
+	ExDates      []time.Time
+	RecurrenceID time.Time
+	Declined     bool
+}
+
+// icsProperty splits an unfolded content line into name, params and value,
+// honouring quoted parameter values that may contain ':' or ';'.
+func icsProperty(line string) (name string, params map[string]string, value string) {
+	params = map[string]string{}
+	quoted := false
+	var parts []string
+	last := 0
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			parts = append(parts, line[last:i])
+			last = i + 1
+		case r == ':' && !quoted:
+			parts = append(parts, line[last:i])
+			value = line[i+1:]
+			name = strings.ToUpper(parts[0])
+			for _, p := range parts[1:] {
+				if k, v, ok := strings.Cut(p, "="); ok {
+					params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+				}
 			}
+			return
 		}
-		total += mins
-		fmt.Printf("%-15s | %s\n", start.Format("Jan"), humanDuration(mins))
 	}
-	fmt.Println(strings.Repeat("-", 50))
-	fmt.Printf("Total working year : %s\n", humanDuration(total))
-	workDaysInYear := 0
-	for m := time.January; m <= time.December; m++ {
-		start := time.Date(year, m, 1, 0, 0, 0, 0, loc)
-		next := start.AddDate(0, 1, 0)
-		for d := start; d.Before(next); d = d.AddDate(0, 0, 1) {
-			if isWorkDay(d, s.Config.WorkDays) {
-				workDaysInYear++
+	return strings.ToUpper(line), params, ""
+}
+
+func parseICSTime(value string, params map[string]string) (t time.Time, allDay bool, err error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t.Local(), false, err
+	}
+	loc := time.Local
+	if tz := strings.Trim(params["TZID"], `"`); tz != "" {
+		if loc, err = icsLocation(tz); err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var errUnknownZone = errors.New("unknown time zone")
+
+// windowsZones maps the Windows zone names that Outlook and Exchange put in
+// TZID to IANA names.
+var windowsZones = map[string]string{
+	"Dateline Standard Time": "Etc/GMT+12", "Hawaiian Standard Time": "Pacific/Honolulu",
+	"Alaskan Standard Time": "America/Anchorage", "Pacific Standard Time": "America/Los_Angeles",
+	"US Mountain Standard Time": "America/Phoenix", "Mountain Standard Time": "America/Denver",
+	"Central Standard Time": "America/Chicago", "Central America Standard Time": "America/Guatemala",
+	"Canada Central Standard Time": "America/Regina", "Central Standard Time (Mexico)": "America/Mexico_City",
+	"Eastern Standard Time": "America/New_York", "US Eastern Standard Time": "America/Indianapolis",
+	"SA Pacific Standard Time": "America/Bogota", "Atlantic Standard Time": "America/Halifax",
+	"Newfoundland Standard Time": "America/St_Johns", "E. South America Standard Time": "America/Sao_Paulo",
+	"Argentina Standard Time": "America/Buenos_Aires", "Pacific SA Standard Time": "America/Santiago",
+	"UTC": "UTC", "Coordinated Universal Time": "UTC", "GMT Standard Time": "Europe/London",
+	"Greenwich Standard Time": "Atlantic/Reykjavik", "W. Europe Standard Time": "Europe/Berlin",
+	"Central Europe Standard Time": "Europe/Budapest", "Romance Standard Time": "Europe/Paris",
+	"Central European Standard Time": "Europe/Warsaw", "W. Central Africa Standard Time": "Africa/Lagos",
+	"GTB Standard Time": "Europe/Bucharest", "FLE Standard Time": "Europe/Kiev",
+	"E. Europe Standard Time": "Europe/Chisinau", "Egypt Standard Time": "Africa/Cairo",
+	"South Africa Standard Time": "Africa/Johannesburg", "Israel Standard Time": "Asia/Jerusalem",
+	"Turkey Standard Time": "Europe/Istanbul", "Russian Standard Time": "Europe/Moscow",
+	"Arab Standard Time": "Asia/Riyadh", "Arabian Standard Time": "Asia/Dubai",
+	"Iran Standard Time": "Asia/Tehran", "Pakistan Standard Time": "Asia/Karachi",
+	"India Standard Time": "Asia/Calcutta", "Nepal Standard Time": "Asia/Katmandu",
+	"Bangladesh Standard Time": "Asia/Dhaka", "SE Asia Standard Time": "Asia/Bangkok",
+	"China Standard Time": "Asia/Shanghai", "Singapore Standard Time": "Asia/Singapore",
+	"Taipei Standard Time": "Asia/Taipei", "W. Australia Standard Time": "Australia/Perth",
+	"Tokyo Standard Time": "Asia/Tokyo", "Korea Standard Time": "Asia/Seoul",
+	"Cen. Australia Standard Time": "Australia/Adelaide", "AUS Eastern Standard Time": "Australia/Sydney",
+	"E. Australia Standard Time": "Australia/Brisbane", "New Zealand Standard Time": "Pacific/Auckland",
+}
+
+// icsLocation resolves a TZID, which is an IANA name or a Windows zone name.
+func icsLocation(tz string) (*time.Location, error) {
+	if name, ok := windowsZones[tz]; ok {
+		tz = name
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", errUnknownZone, tz)
+	}
+	return loc, nil
+}
+
+var icsDurationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(value string) (time.Duration, error) {
+	m := icsDurationRe.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, u := range units {
+		if n, err := strconv.Atoi(m[i+2]); err == nil {
+			d += time.Duration(n) * u
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// parseICS reads the VEVENTs of a calendar. When email is set, events where
+// that attendee declined are marked as such.
+func parseICS(data []byte, email string) (events []icsEvent, skipped []string, err error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+
+	var ev *icsEvent
+	var duration time.Duration
+	var badZone error
+	for _, line := range strings.Split(text, "\n") {
+		name, params, value := icsProperty(strings.TrimRight(line, "\r"))
+		if name == "BEGIN" && value == "VEVENT" {
+			ev, duration, badZone = &icsEvent{}, 0, nil
+			continue
+		}
+		if ev == nil {
+			continue
+		}
+		var err error
+		switch name {
+		case "END":
+			if value == "VEVENT" {
+				if ev.End.IsZero() {
+					switch {
+					case duration > 0:
+						ev.End = ev.Start.Add(duration)
+					case ev.AllDay:
+						ev.End = ev.Start.AddDate(0, 0, 1)
+					default:
+						ev.End = ev.Start
+					}
+				}
+				// Guessing the zone would move the meeting by hours.
+				if badZone != nil {
+					skipped = append(skipped, fmt.Sprintf("%s (%s): %v", ev.Summary, ev.UID, badZone))
+				} else if !ev.Start.IsZero() {
+					events = append(events, *ev)
+				}
+				ev = nil
+			}
+		case "UID":
+			ev.UID = value
+		case "SUMMARY":
+			ev.Summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(value)
+		case "STATUS":
+			ev.Status = strings.ToUpper(value)
+		case "TRANSP":
+			ev.Transparent = strings.ToUpper(value) == "TRANSPARENT"
+		case "DTSTART":
+			ev.Start, ev.AllDay, err = parseICSTime(value, params)
+		case "DTEND":
+			ev.End, _, err = parseICSTime(value, params)
+		case "DURATION":
+			duration, err = parseICSDuration(value)
+		case "RECURRENCE-ID":
+			ev.RecurrenceID, _, err = parseICSTime(value, params)
+		case "RRULE":
+			ev.RRule = map[string]string{}
+			for _, part := range strings.Split(value, ";") {
+				if k, v, ok := strings.Cut(part, "="); ok {
+					ev.RRule[strings.ToUpper(k)] = strings.ToUpper(v)
+				}
+			}
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseICSTime(v, params); err == nil {
+					ev.ExDates = append(ev.ExDates, t)
+				}
+			}
+		case "ATTENDEE":
+			addr := strings.TrimPrefix(strings.ToLower(value), "mailto:")
+			if email != "" && addr == strings.ToLower(email) && strings.ToUpper(params["PARTSTAT"]) == "DECLINED" {
+				ev.Declined = true
+			}
+		}
+		if errors.Is(err, errUnknownZone) {
+			badZone = err
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("event %s: %s: %w", ev.UID, name, err)
+		}
+	}
+	return events, skipped, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// expandICSEvent returns the start times of the occurrences of ev that begin
+// before to and end after from. It understands DAILY, WEEKLY (with BYDAY and
+// WKST), MONTHLY (with BYDAY, BYMONTHDAY and BYSETPOS) and YEARLY rules with
+// INTERVAL, COUNT and UNTIL.
+func expandICSEvent(ev icsEvent, from, to time.Time) []time.Time {
+	length := ev.End.Sub(ev.Start)
+	excluded := func(t time.Time) bool {
+		for _, x := range ev.ExDates {
+			if x.Equal(t) {
+				return true
+			}
+		}
+		return false
+	}
+	var out []time.Time
+	add := func(t time.Time) {
+		if t.Before(to) && t.Add(length).After(from) && !excluded(t) {
+			out = append(out, t)
+		}
+	}
+	if ev.RRule == nil {
+		add(ev.Start)
+		return out
+	}
+
+	interval, _ := strconv.Atoi(ev.RRule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(ev.RRule["COUNT"])
+	var until time.Time
+	if u := ev.RRule["UNTIL"]; u != "" {
+		until, _, _ = parseICSTime(u, map[string]string{})
+		if len(u) == 8 {
+			until = until.AddDate(0, 0, 1).Add(-time.Second)
+		}
+	}
+	// BYDAY entries like TU, 2TU (second Tuesday) or -1FR (last Friday).
+	type weekdayNum struct {
+		ord int
+		day time.Weekday
+	}
+	var byDay []weekdayNum
+	for _, d := range strings.Split(ev.RRule["BYDAY"], ",") {
+		num := strings.TrimRight(d, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		if wd, ok := icsWeekdays[d[len(num):]]; ok {
+			ord, _ := strconv.Atoi(strings.TrimPrefix(num, "+"))
+			byDay = append(byDay, weekdayNum{ord, wd})
+		}
+	}
+	ints := func(key string) []int {
+		var out []int
+		for _, v := range strings.Split(ev.RRule[key], ",") {
+			if n, err := strconv.Atoi(strings.TrimPrefix(v, "+")); err == nil && n != 0 {
+				out = append(out, n)
+			}
+		}
+		return out
+	}
+	byMonthDay, bySetPos := ints("BYMONTHDAY"), ints("BYSETPOS")
+	wkst := time.Monday
+	if wd, ok := icsWeekdays[ev.RRule["WKST"]]; ok {
+		wkst = wd
+	}
+
+	loc := ev.Start.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, ev.Start.Hour(), ev.Start.Minute(), ev.Start.Second(), 0, loc)
+	}
+	// monthDays lists the days of a month the rule picks, in order.
+	monthDays := func(y int, m time.Month) []int {
+		last := time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day()
+		pick := make([]bool, last+1)
+		for _, md := range byMonthDay {
+			if md < 0 {
+				md += last + 1
+			}
+			if md >= 1 && md <= last {
+				pick[md] = true
+			}
+		}
+		if len(byDay) > 0 {
+			inDays := make([]bool, last+1)
+			for _, b := range byDay {
+				var matches []int
+				for d := 1; d <= last; d++ {
+					if time.Date(y, m, d, 0, 0, 0, 0, loc).Weekday() == b.day {
+						matches = append(matches, d)
+					}
+				}
+				switch {
+				case b.ord == 0:
+					for _, d := range matches {
+						inDays[d] = true
+					}
+				case b.ord > 0 && b.ord <= len(matches):
+					inDays[matches[b.ord-1]] = true
+				case b.ord < 0 && -b.ord <= len(matches):
+					inDays[matches[len(matches)+b.ord]] = true
+				}
+			}
+			// BYDAY narrows BYMONTHDAY when both are given.
+			for d := 1; d <= last; d++ {
+				pick[d] = inDays[d] && (len(byMonthDay) == 0 || pick[d])
+			}
+		} else if len(byMonthDay) == 0 && ev.Start.Day() <= last {
+			pick[ev.Start.Day()] = true
+		}
+		var days []int
+		for d := 1; d <= last; d++ {
+			if pick[d] {
+				days = append(days, d)
+			}
+		}
+		if len(bySetPos) == 0 {
+			return days
+		}
+		var set []int
+		for _, pos := range bySetPos {
+			if pos < 0 {
+				pos += len(days) + 1
+			}
+			if pos >= 1 && pos <= len(days) {
+				set = append(set, days[pos-1])
+			}
+		}
+		slices.Sort(set)
+		return slices.Compact(set)
+	}
+	firstWeek := startOfDay(ev.Start).AddDate(0, 0, -((int(ev.Start.Weekday()) - int(wkst) + 7) % 7))
+	seen := 0
+	for n := 0; n < 5000; n++ {
+		var candidates []time.Time
+		switch ev.RRule["FREQ"] {
+		case "DAILY":
+			candidates = []time.Time{ev.Start.AddDate(0, 0, n*interval)}
+		case "WEEKLY":
+			if len(byDay) == 0 {
+				candidates = []time.Time{ev.Start.AddDate(0, 0, n*7*interval)}
+			}
+			weekStart := firstWeek.AddDate(0, 0, n*7*interval)
+			for i := 0; i < 7; i++ {
+				d := weekStart.AddDate(0, 0, i)
+				if slices.ContainsFunc(byDay, func(b weekdayNum) bool { return b.day == d.Weekday() }) {
+					candidates = append(candidates, at(d.Year(), d.Month(), d.Day()))
+				}
+			}
+		case "MONTHLY":
+			first := time.Date(ev.Start.Year(), ev.Start.Month()+time.Month(n*interval), 1, 0, 0, 0, 0, loc)
+			for _, d := range monthDays(first.Year(), first.Month()) {
+				candidates = append(candidates, at(first.Year(), first.Month(), d))
 			}
+		case "YEARLY":
+			d := at(ev.Start.Year()+n*interval, ev.Start.Month(), ev.Start.Day())
+			if d.Day() == ev.Start.Day() {
+				candidates = []time.Time{d}
+			}
+		default:
+			add(ev.Start)
+			return out
+		}
+		for _, c := range candidates {
+			if c.Before(ev.Start) {
+				continue
+			}
+			if (count > 0 && seen >= count) || (!until.IsZero() && c.After(until)) || !c.Before(to) {
+				return out
+			}
+			seen++
+			add(c)
 		}
 	}
-	expectedMins := workDaysInYear * s.Config.DailyGoalMinutes
-	fmt.Printf("Goal progress: %s\n", formatPercentage(total, expectedMins))
+	return out
 }
 
-func report(s *Store, rng string) {
-	now := time.Now()
-	switch rng {
-	case "today":
-		reportToday(s)
-	case "week":
-		// ISO week: Monday start
-		weekday := int(now.Weekday())
-		if weekday == 0 { // Sunday -> 7
-			weekday = 7
+// calendarMeetings turns the timed, accepted events of a calendar into
+// meetings overlapping [from, to). All-day events are left out.
+func calendarMeetings(events []icsEvent, calendar, tag string, from, to time.Time) []Meeting {
+	// Modified occurrences replace the instance of the series they point at.
+	overridden := map[string][]time.Time{}
+	for _, ev := range events {
+		if !ev.RecurrenceID.IsZero() {
+			overridden[ev.UID] = append(overridden[ev.UID], ev.RecurrenceID)
+		}
+	}
+	var meetings []Meeting
+	for _, ev := range events {
+		if ev.AllDay || ev.Declined || ev.Transparent || ev.Status == "CANCELLED" || !ev.End.After(ev.Start) {
+			continue
+		}
+		if ev.RecurrenceID.IsZero() {
+			ev.ExDates = append(ev.ExDates, overridden[ev.UID]...)
+		}
+		for _, start := range expandICSEvent(ev, from, to) {
+			meetings = append(meetings, Meeting{
+				Calendar: calendar,
+				UID:      ev.UID,
+				Summary:  ev.Summary,
+				Start:    start.Unix(),
+				End:      start.Add(ev.End.Sub(ev.Start)).Unix(),
+				Tag:      tag,
+			})
 		}
-		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(weekday - 1))
-		reportAggregateDaily(s, start, 7, fmt.Sprintf("for week starting %s", start.Format("2006-01-02")))
-	case "month":
-		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		next := start.AddDate(0, 1, 0)
-		days := int(next.Sub(start).Hours() / 24)
-		reportAggregateDaily(s, start, days, fmt.Sprintf("for month %s", start.Format("2006-01")))
-	case "year":
-		reportYearMonthly(s, now.Year())
-	default:
-		fmt.Printf("Unknown range '%s'\n", rng)
 	}
+	sort.Slice(meetings, func(i, j int) bool { return meetings[i].Start < meetings[j].Start })
+	return meetings
 }
 
-func todayTotals(s *Store) (workMins, idleMins int) {
-	now := time.Now()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	end := now
-	var seq []time.Time
-	for cur := floorToBin(start); cur.Before(floorToBin(end)); cur = cur.Add(binMinutes * time.Minute) {
-		seq = append(seq, cur)
+// readCalendar loads an ICS file, or fetches a URL and keeps a copy next to
+// the store so that a later sync works offline.
+func readCalendar(source, storePath string) ([]byte, error) {
+	if !strings.Contains(source, "://") {
+		return os.ReadFile(source)
 	}
-	status := map[time.Time]int{}
-	for _, t := range seq {
-		status[t] = 0
+	url := source
+	if strings.HasPrefix(url, "webcal://") {
+		url = "https://" + strings.TrimPrefix(url, "webcal://")
 	}
-	bins := fetchBins(s, start, end)
-	for t, v := range bins {
-		status[t] = v
+	sum := sha1.Sum([]byte(source))
+	cache := strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".calendar-" + hex.EncodeToString(sum[:4]) + ".ics"
+
+	client := &http.Client{Timeout: 20 * time.Second}
+	resp, err := client.Get(url)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("fetch %s: %s", url, resp.Status)
+		}
 	}
-	for _, t := range seq {
-		if status[t] == 1 {
-			workMins += binMinutes
-		} else {
-			idleMins += binMinutes
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		cached, cacheErr := os.ReadFile(cache)
+		if cacheErr != nil {
+			return nil, err
 		}
+		fmt.Fprintf(os.Stderr, "[calendar] %v; using cached copy\n", err)
+		return cached, nil
 	}
-	return
+	if err := os.WriteFile(cache, data, 0600); err != nil {
+		fmt.Fprintln(os.Stderr, "[calendar] could not cache calendar:", err)
+	}
+	return data, nil
 }
 
-func ensureStartupAtLogin(execPath string) {
-	usr, err := user.Current()
-	if err != nil {
-		return
+func cmdCalendar(file string, args []string) error {
+	if len(args) == 0 || args[0] != "sync" {
+		return fmt.Errorf("usage: calendar sync --ics PATH|URL [--email ADDRESS] [--tag meeting]")
+	}
+	fs, path := newCommandFlags("calendar sync", file)
+	source := fs.String("ics", "", "ICS file or URL to import")
+	email := fs.String("email", "", "your attendee address, used to skip declined events")
+	tag := fs.String("tag", "meeting", "tag applied to meeting time")
+	back := fs.Int("days-back", 90, "import meetings this many days into the past")
+	ahead := fs.Int("days-ahead", 30, "import meetings this many days into the future")
+	fs.Parse(args[1:])
+	if *source == "" {
+		return fmt.Errorf("calendar sync: --ics is required")
 	}
-	uid := usr.Uid
-	base := strings.TrimSuffix(filepath.Base(execPath), filepath.Ext(execPath))
-	base = strings.ToLower(strings.ReplaceAll(base, " ", "-"))
-	label := "com." + base + ".autostart"
-
-	agentsDir := filepath.Join(usr.HomeDir, "Library", "LaunchAgents")
-	plistPath := filepath.Join(agentsDir, label+".plist")
 
-	installed := false
-	if _, err := os.Stat(plistPath); err == nil {
-		if err := exec.Command("launchctl", "print", "gui/"+uid+"/"+label).Run(); err == nil {
-			installed = true
-		}
+	data, err := readCalendar(*source, *path)
+	if err != nil {
+		return fmt.Errorf("read calendar: %w", err)
 	}
-	if installed {
-		return
+	events, skipped, err := parseICS(data, *email)
+	if err != nil {
+		return fmt.Errorf("parse calendar: %w", err)
 	}
-
-	fmt.Print("[startup] This app is not set to launch at login. Add it now? [y/N]: ")
-	rd := bufio.NewReader(os.Stdin)
-	ans, _ := rd.ReadString('\n')
-	ans = strings.TrimSpace(strings.ToLower(ans))
-	if ans != "y" && ans != "yes" {
-		fmt.Println("[startup] Skipping adding to startup.")
-		return
+	for _, sk := range skipped {
+		fmt.Fprintln(os.Stderr, "skipped event", sk)
 	}
 
-	_ = os.MkdirAll(agentsDir, 0755)
-	outLog := filepath.Join(agentsDir, label+".out.log")
-	errLog := filepath.Join(agentsDir, label+".err.log")
-	dataFile := filepath.Join(filepath.Dir(execPath), "timetrackcli.json")
-	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0"><dict>
-  <key>Label</key><string>%s</string>
-  <key>ProgramArguments</key><array><string>%s</string><string>--file</string><string>%s</string></array>
-  <key>RunAtLoad</key><true/>
-  <key>KeepAlive</key><true/>
-  <key>WorkingDirectory</key><string>%s</string>
-  <key>StandardOutPath</key><string>%s</string>
-  <key>StandardErrorPath</key><string>%s</string>
-</dict></plist>`, label, execPath, dataFile, filepath.Dir(execPath), outLog, errLog)
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+	now := time.Now()
+	from := now.AddDate(0, 0, -*back)
+	to := now.AddDate(0, 0, *ahead)
+	meetings := calendarMeetings(events, *source, *tag, from, to)
+	for i := range meetings {
+		meetings[i].Modified = now.Unix()
+	}
 
-	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
-		fmt.Println("[startup] Failed to write LaunchAgent:", err)
-		return
+	// Replace what this calendar contributed before within the synced window.
+	fresh := map[string]bool{}
+	for _, mt := range meetings {
+		fresh[meetingKey(mt)] = true
 	}
+	kept := store.Meetings[:0]
+	for _, mt := range store.Meetings {
+		if mt.Calendar != *source || mt.End <= from.Unix() || mt.Start >= to.Unix() {
+			kept = append(kept, mt)
+		} else if !fresh[meetingKey(mt)] {
+			markRemoved(store, "meeting", meetingKey(mt), now)
+		}
+	}
+	store.Meetings = append(kept, meetings...)
+	sort.Slice(store.Meetings, func(i, j int) bool { return store.Meetings[i].Start < store.Meetings[j].Start })
 
-	if err := exec.Command("launchctl", "bootstrap", "gui/"+uid, plistPath).Run(); err != nil {
-		_ = exec.Command("launchctl", "load", "-w", plistPath).Run()
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
 	}
-	_ = exec.Command("launchctl", "enable", "gui/"+uid+"/"+label).Run()
-	_ = exec.Command("launchctl", "kickstart", "-k", "gui/"+uid+"/"+label).Run()
-	fmt.Println("[startup] Added to login (LaunchAgents):", plistPath)
+	fmt.Printf("Imported %d meetings from %s\n", len(meetings), *source)
+	return nil
 }
 
-func parseTimeToMinutes(timeStr string) (int, error) {
-	parts := strings.Split(timeStr, ":")
-	if len(parts) != 2 {
-		return 0, fmt.Errorf("invalid time format, use HH:MM")
-	}
-	hours, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, err
-	}
-	mins, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, err
+// addDayOff records a day off, replacing an existing entry for the same date.
+func addDayOff(s *Store, off DayOff) {
+	off.Modified = time.Now().Unix()
+	for i := range s.DaysOff {
+		if s.DaysOff[i].Date == off.Date {
+			s.DaysOff[i] = off
+			return
+		}
 	}
-	return hours*60 + mins, nil
+	s.DaysOff = append(s.DaysOff, off)
+	sort.Slice(s.DaysOff, func(i, j int) bool { return s.DaysOff[i].Date < s.DaysOff[j].Date })
 }
 
-func parseWorkDays(workDaysStr string) ([]int, error) {
-	dayMap := map[string]int{
-		"mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6, "sun": 7,
+func cmdOff(file string, args []string) error {
+	usage := fmt.Errorf("usage: off add DATE [--to DATE] [--reason TEXT] [--half] | remove DATE | list | import --ics PATH|URL")
+	if len(args) == 0 {
+		return usage
+	}
+	fs, path := newCommandFlags("off "+args[0], file)
+	reason := fs.String("reason", "", "why you are off (vacation, holiday, sick, ...)")
+	half := fs.Bool("half", false, "only half of the daily goal is dropped")
+	to := fs.String("to", "", "last day of a multi-day absence")
+	ics := fs.String("ics", "", "holiday calendar (file or URL) whose all-day events are days off")
+	dates := parseArgs(fs, args[1:])
+
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
 	}
 
-	var days []int
-	if strings.Contains(workDaysStr, "-") {
-		parts := strings.Split(workDaysStr, "-")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid range format")
+	switch args[0] {
+	case "add":
+		if len(dates) != 1 {
+			return usage
 		}
-		start, ok1 := dayMap[strings.ToLower(parts[0])]
-		end, ok2 := dayMap[strings.ToLower(parts[1])]
-		if !ok1 || !ok2 {
-			return nil, fmt.Errorf("invalid day names")
+		first, err := parseDateArg(dates[0], false)
+		if err != nil {
+			return err
 		}
-		for i := start; i <= end; i++ {
-			days = append(days, i)
+		last := first
+		if *to != "" {
+			if last, err = parseDateArg(*to, false); err != nil {
+				return err
+			}
 		}
-	} else {
-		parts := strings.Split(workDaysStr, ",")
-		for _, part := range parts {
-			day, ok := dayMap[strings.ToLower(strings.TrimSpace(part))]
-			if !ok {
-				return nil, fmt.Errorf("invalid day name: %s", part)
+		n := 0
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			if *to != "" && !isWorkDay(d, store.Config.WorkDays) {
+				continue
+			}
+			addDayOff(store, DayOff{Date: d.Format("2006-01-02"), Reason: *reason, Half: *half})
+			n++
+		}
+		fmt.Printf("Added %d day(s) off\n", n)
+	case "remove":
+		if len(dates) != 1 {
+			return usage
+		}
+		d, err := parseDateArg(dates[0], false)
+		if err != nil {
+			return err
+		}
+		date := d.Format("2006-01-02")
+		kept := store.DaysOff[:0]
+		for _, off := range store.DaysOff {
+			if off.Date != date {
+				kept = append(kept, off)
+			}
+		}
+		if len(kept) == len(store.DaysOff) {
+			return fmt.Errorf("no day off on %s", date)
+		}
+		store.DaysOff = kept
+		markRemoved(store, "dayoff", date, time.Now())
+		fmt.Println("Removed day off on", date)
+	case "list":
+		if len(store.DaysOff) == 0 {
+			fmt.Println("No days off recorded")
+		}
+		for _, off := range store.DaysOff {
+			fmt.Printf("%s  %s\n", off.Date, off.label())
+		}
+		return nil
+	case "import":
+		if *ics == "" {
+			return fmt.Errorf("off import: --ics is required")
+		}
+		data, err := readCalendar(*ics, *path)
+		if err != nil {
+			return fmt.Errorf("read calendar: %w", err)
+		}
+		events, skipped, err := parseICS(data, "")
+		if err != nil {
+			return fmt.Errorf("parse calendar: %w", err)
+		}
+		for _, sk := range skipped {
+			fmt.Fprintln(os.Stderr, "skipped event", sk)
+		}
+		now := time.Now()
+		from, until := now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0)
+		n := 0
+		for _, ev := range events {
+			if !ev.AllDay || ev.Status == "CANCELLED" {
+				continue
+			}
+			why := *reason
+			if why == "" {
+				why = ev.Summary
+			}
+			for _, start := range expandICSEvent(ev, from, until) {
+				for d := start; d.Before(start.Add(ev.End.Sub(ev.Start))); d = d.AddDate(0, 0, 1) {
+					addDayOff(store, DayOff{Date: d.Format("2006-01-02"), Reason: why})
+					n++
+				}
 			}
-			days = append(days, day)
 		}
+		fmt.Printf("Imported %d day(s) off from %s\n", n, *ics)
+	default:
+		return usage
 	}
-	return days, nil
-}
 
-func formatPercentage(workMins, goalMins int) string {
-	if goalMins == 0 {
-		return "0%"
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
 	}
-	pct := (workMins * 100) / goalMins
-	return fmt.Sprintf("%d%% of %s", pct, humanDuration(goalMins))
+	return nil
 }
 
-func isWorkDay(t time.Time, workDays []int) bool {
-	weekday := int(t.Weekday())
-	if weekday == 0 {
-		weekday = 7
-	}
-	for _, day := range workDays {
-		if day == weekday {
-			return true
-		}
-	}
-	return false
+func formatClock(mins int) string {
+	return fmt.Sprintf("%02d:%02d", mins/60, mins%60)
 }
 
-func findBestWorstDays(s *Store) (bestDay time.Time, bestMins int, worstDay time.Time, worstMins int) {
-	now := time.Now()
-	bestMins = -1
-	worstMins = 9999
-
-	for dayIndex := 0; dayIndex < 30; dayIndex++ {
-		targetDay := now.AddDate(0, 0, -(29 - dayIndex))
+func cmdGoals(file string, args []string) error {
+	usage := fmt.Errorf("usage: goals list | set --from DATE [--workdays Mon-Fri --daily HH:MM] [--mon HH:MM ...] [--weekly HH:MM] [--monthly HH:MM] | remove --from DATE")
+	if len(args) == 0 {
+		return usage
+	}
+	fs, path := newCommandFlags("goals "+args[0], file)
+	from := fs.String("from", "", "first day the schedule applies to (YYYY-MM-DD)")
+	workdays := fs.String("workdays", "", "days the daily goal applies to, e.g. Mon-Thu")
+	daily := fs.String("daily", "", "goal for each of --workdays (HH:MM)")
+	weekly := fs.String("weekly", "", "weekly target overriding the sum of daily goals (HH:MM)")
+	monthly := fs.String("monthly", "", "monthly target overriding the sum of daily goals (HH:MM)")
+	perDay := map[string]*string{}
+	for _, name := range weekdayNames {
+		perDay[name] = fs.String(name, "", "goal for "+name+" (HH:MM, 00:00 for a day off)")
+	}
+	fs.Parse(args[1:])
 
-		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		bins := fetchBins(s, dayStart, dayEnd)
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
 
-		workMins := 0
-		for _, v := range bins {
-			if v == 1 {
-				workMins += binMinutes
+	switch args[0] {
+	case "list":
+		fmt.Printf("Default: %s on %v\n", formatClock(store.Config.DailyGoalMinutes), store.Config.WorkDays)
+		for _, sch := range store.Config.Schedules {
+			line := "From " + sch.From + ":"
+			for _, name := range weekdayNames {
+				if mins := sch.Weekdays[name]; mins > 0 {
+					line += fmt.Sprintf(" %s %s", name, formatClock(mins))
+				}
+			}
+			if sch.WeeklyMinutes > 0 {
+				line += " | weekly " + formatClock(sch.WeeklyMinutes)
 			}
+			if sch.MonthlyMinutes > 0 {
+				line += " | monthly " + formatClock(sch.MonthlyMinutes)
+			}
+			fmt.Println(line)
 		}
-
-		// Skip days with no work
-		if workMins == 0 {
-			continue
+		return nil
+	case "set":
+		if *from == "" {
+			return fmt.Errorf("goals set: --from is required")
 		}
-
-		// Check for best day
-		if workMins > bestMins {
-			bestMins = workMins
-			bestDay = targetDay
+		day, err := parseDateArg(*from, false)
+		if err != nil {
+			return err
 		}
-
-		// Check for worst day (but not zero)
-		if workMins < worstMins {
-			worstMins = workMins
-			worstDay = targetDay
+		// Schedules are ordered by comparing these strings.
+		sch := Schedule{From: day.Format("2006-01-02")}
+		if *workdays != "" || *daily != "" {
+			if *workdays == "" || *daily == "" {
+				return fmt.Errorf("goals set: --workdays and --daily go together")
+			}
+			days, err := parseWorkDays(*workdays)
+			if err != nil {
+				return err
+			}
+			mins, err := parseTimeToMinutes(*daily)
+			if err != nil {
+				return err
+			}
+			sch.Weekdays = map[string]int{}
+			for _, d := range days {
+				sch.Weekdays[weekdayNames[d-1]] = mins
+			}
 		}
-	}
-
-	return
-}
-
-func create30DayGrid(s *Store, width int) string {
-	now := time.Now()
-	grid := "📅 LAST 30 DAYS\n\n"
-
-	line := ""
-	for dayIndex := 0; dayIndex < 30; dayIndex++ {
-		targetDay := now.AddDate(0, 0, -(29 - dayIndex))
-
-		// Get working minutes for this day
-		dayStart := time.Date(targetDay.Year(), targetDay.Month(), targetDay.Day(), 0, 0, 0, 0, targetDay.Location())
-		dayEnd := dayStart.Add(24 * time.Hour)
-		bins := fetchBins(s, dayStart, dayEnd)
-
-		workMins := 0
-		for _, v := range bins {
-			if v == 1 {
-				workMins += binMinutes
+		for name, v := range perDay {
+			if *v == "" {
+				continue
+			}
+			mins, err := parseTimeToMinutes(*v)
+			if err != nil {
+				return fmt.Errorf("--%s: %w", name, err)
+			}
+			if sch.Weekdays == nil {
+				// Per-day overrides start from the current default week.
+				sch.Weekdays = map[string]int{}
+				for _, d := range store.Config.WorkDays {
+					sch.Weekdays[weekdayNames[d-1]] = store.Config.DailyGoalMinutes
+				}
+			}
+			if mins == 0 {
+				delete(sch.Weekdays, name)
+			} else {
+				sch.Weekdays[name] = mins
 			}
 		}
-
-		// Determine symbol based on work hours
-		var symbol string
-
-		if workMins == 0 {
-			symbol = "⚫" // Gray circle - no data
-		} else if workMins < 120 { // Less than 2 hours
-			symbol = "⚪" // White circle
-		} else if workMins <= 300 { // 2-5 hours
-			symbol = "🟡" // Yellow circle
-		} else { // Above 5 hours
-			symbol = "🟢" // Green circle
+		for _, target := range []struct {
+			flag string
+			dst  *int
+		}{{*weekly, &sch.WeeklyMinutes}, {*monthly, &sch.MonthlyMinutes}} {
+			if target.flag == "" {
+				continue
+			}
+			if *target.dst, err = parseTimeToMinutes(target.flag); err != nil {
+				return err
+			}
 		}
-
-		// Use checkmark if it's a workday and meets goal
-		if isWorkDay(targetDay, s.Config.WorkDays) && workMins >= s.Config.DailyGoalMinutes {
-			symbol = "✅"
+		replaced := false
+		for i := range store.Config.Schedules {
+			if store.Config.Schedules[i].From == sch.From {
+				store.Config.Schedules[i], replaced = sch, true
+			}
 		}
-
-		line += symbol
+		if !replaced {
+			store.Config.Schedules = append(store.Config.Schedules, sch)
+			sort.Slice(store.Config.Schedules, func(i, j int) bool {
+				return store.Config.Schedules[i].From < store.Config.Schedules[j].From
+			})
+		}
+		fmt.Println("Schedule set from", sch.From)
+	case "remove":
+		kept := store.Config.Schedules[:0]
+		for _, sch := range store.Config.Schedules {
+			if sch.From != *from {
+				kept = append(kept, sch)
+			}
+		}
+		if len(kept) == len(store.Config.Schedules) {
+			return fmt.Errorf("no schedule starts on %q", *from)
+		}
+		store.Config.Schedules = kept
+		fmt.Println("Removed schedule from", *from)
+	default:
+		return usage
 	}
 
-	grid += line + "\n\n"
-	grid += "⚫ No data  ⚪ <2hrs  🟡 2-5hrs  🟢 >5hrs  ✅ Goal met"
-	return grid
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	return nil
 }
 
-func calculateFocusStats(s *Store) (longestFocus int, contextSwitches int) {
-	now := time.Now()
-	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	bins := fetchBins(s, start, now)
-
-	// Create full sequence from midnight to now
-	var seq []time.Time
-	for cur := floorToBin(start); cur.Before(floorToBin(now)); cur = cur.Add(binMinutes * time.Minute) {
-		seq = append(seq, cur)
+// parseSignedClock parses [+-]HH:MM into minutes.
+func parseSignedClock(value string) (int, error) {
+	sign := 1
+	if strings.HasPrefix(value, "-") {
+		sign = -1
 	}
+	mins, err := parseTimeToMinutes(strings.TrimLeft(value, "+-"))
+	return sign * mins, err
+}
 
-	status := map[time.Time]int{}
-	for _, t := range seq {
-		status[t] = 0
+func cmdBalance(file string, args []string) error {
+	sub := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
 	}
-	for t, v := range bins {
-		status[t] = v
+	fs, path := newCommandFlags("balance "+sub, file)
+	reason := fs.String("reason", "", "why the balance is adjusted")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "day the adjustment applies to")
+	weeks := fs.Int("weeks", 6, "number of past weeks to list")
+	// `balance add -10:00` would otherwise be read as a flag.
+	var amount string
+	if sub == "add" && len(args) > 0 {
+		amount, args = args[0], args[1:]
 	}
+	fs.Parse(args)
 
-	if len(seq) == 0 {
-		return 0, 0
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
 	}
 
-	// Find longest working session and count context switches
-	longestFocus = 0
-	currentFocus := 0
-	prevStatus := status[seq[0]]
-
-	for _, t := range seq {
-		currentStatus := status[t]
-
-		if currentStatus == 1 { // Working
-			currentFocus += binMinutes
-			if currentFocus > longestFocus {
-				longestFocus = currentFocus
+	switch sub {
+	case "show":
+		today, week, total := calculateBalance(store)
+		fmt.Printf("Flextime balance since %s\n", balanceStart(store).Format("2006-01-02"))
+		fmt.Println(strings.Repeat("-", 50))
+		now := time.Now()
+		weekStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+		fmt.Printf("%-15s | %-14s | %-14s | %s\n", "Week", "Worked", "Expected", "Balance")
+		fmt.Println(strings.Repeat("-", 50))
+		for i := *weeks - 1; i >= 1; i-- {
+			ws := weekStart.AddDate(0, 0, -7*i)
+			worked, expected := 0, 0
+			for _, mins := range workedByDay(store, ws, ws.AddDate(0, 0, 7)) {
+				worked += mins
 			}
-		} else { // Idle
-			currentFocus = 0
+			for d := ws; d.Before(ws.AddDate(0, 0, 7)); d = d.AddDate(0, 0, 1) {
+				expected += expectedMinutes(store, d)
+			}
+			fmt.Printf("%-15s | %-14s | %-14s | %s\n", ws.Format("2006-01-02"), humanDuration(worked), humanDuration(expected), signedDuration(worked-expected))
 		}
-
-		// Count context switches (status changes)
-		if currentStatus != prevStatus {
-			contextSwitches++
+		fmt.Println(strings.Repeat("-", 50))
+		for _, a := range store.Adjustments {
+			fmt.Printf("Adjustment %s: %s %s\n", a.Date, signedDuration(a.Minutes), a.Reason)
 		}
-		prevStatus = currentStatus
+		fmt.Printf("Today        : %s\n", signedDuration(today))
+		fmt.Printf("Week to date : %s\n", signedDuration(week))
+		fmt.Printf("All time     : %s\n", signedDuration(total))
+		return nil
+	case "add":
+		mins, err := parseSignedClock(amount)
+		if err != nil || amount == "" {
+			return fmt.Errorf("usage: balance add [+-]HH:MM [--reason TEXT] [--date YYYY-MM-DD]")
+		}
+		if _, err := parseDateArg(*date, false); err != nil {
+			return err
+		}
+		store.Adjustments = append(store.Adjustments, Adjustment{Date: *date, Minutes: mins, Reason: *reason})
+		fmt.Printf("Adjusted balance by %s\n", signedDuration(mins))
+	default:
+		return fmt.Errorf("usage: balance [show] [--weeks N] | add [+-]HH:MM [--reason TEXT]")
 	}
 
-	return longestFocus, contextSwitches
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	return nil
 }
 
-func calculatePeriodProgress(s *Store) (weekHours, weekGoal, monthHours, monthGoal, yearHours, yearGoal int) {
-	now := time.Now()
-
-	// Week calculation (ISO week: Monday start)
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		weekday = 7
+func cmdUndo(file string, args []string, redo bool) error {
+	name, step, verb := "undo", undoChange, "Undid"
+	if redo {
+		name, step, verb = "redo", redoChange, "Redid"
 	}
-	weekStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(weekday - 1))
-	weekEnd := weekStart.AddDate(0, 0, 7)
-
-	// Month calculation
-	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	monthEnd := monthStart.AddDate(0, 1, 0)
-
-	// Year calculation
-	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
-	yearEnd := yearStart.AddDate(1, 0, 0)
+	fs, path := newCommandFlags(name, file)
+	list := fs.Bool("list", false, "show the change history instead")
+	fs.Parse(args)
 
-	// Calculate week hours and goal
-	weekBins := fetchBins(s, weekStart, weekEnd)
-	for _, v := range weekBins {
-		if v == 1 {
-			weekHours += binMinutes
-		}
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
 	}
-	for d := weekStart; d.Before(weekEnd); d = d.AddDate(0, 0, 1) {
-		if isWorkDay(d, s.Config.WorkDays) {
-			weekGoal += s.Config.DailyGoalMinutes
+	if *list {
+		if len(store.History) == 0 {
+			fmt.Println("No changes recorded")
 		}
+		for _, c := range store.History {
+			state := ""
+			if c.Undone {
+				state = " (undone)"
+			}
+			fmt.Printf("%s  %s%s\n", time.Unix(c.Time, 0).Format("2006-01-02 15:04"), c.Action, state)
+		}
+		return nil
 	}
 
-	// Calculate month hours and goal
-	monthBins := fetchBins(s, monthStart, monthEnd)
-	for _, v := range monthBins {
-		if v == 1 {
-			monthHours += binMinutes
-		}
+	c, err := step(store)
+	if c == nil {
+		return err
 	}
-	for d := monthStart; d.Before(monthEnd); d = d.AddDate(0, 0, 1) {
-		if isWorkDay(d, s.Config.WorkDays) {
-			monthGoal += s.Config.DailyGoalMinutes
-		}
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
 	}
+	fmt.Printf("%s: %s (%s)\n", verb, c.Action, time.Unix(c.Time, 0).Format("2006-01-02 15:04"))
+	return err
+}
 
-	// Calculate year hours and goal
-	yearBins := fetchBins(s, yearStart, yearEnd)
-	for _, v := range yearBins {
-		if v == 1 {
-			yearHours += binMinutes
-		}
-	}
-	for d := yearStart; d.Before(yearEnd); d = d.AddDate(0, 0, 1) {
-		if isWorkDay(d, s.Config.WorkDays) {
-			yearGoal += s.Config.DailyGoalMinutes
+// tagStyleFor colors a tag with its own color or that of its closest parent,
+// falling back to the default tag style.
+func tagStyleFor(s *Store, tag string) lipgloss.Style {
+	ancestors := tagAncestors(tag)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if c := s.TagMeta[ancestors[i]].Color; c != "" {
+			fg := "#000000"
+			if r, g, b, ok := hexColor(c); ok && r*299+g*587+b*114 < 128000 {
+				fg = "#FFFFFF" // light text on dark backgrounds
+			}
+			return tagStyle.Background(lipgloss.Color(c)).Foreground(lipgloss.Color(fg))
 		}
 	}
-
-	return
+	return tagStyle
 }
 
-func calculateTagHours(s *Store, period string) map[string]int {
-	now := time.Now()
-	var start, end time.Time
-
-	switch period {
-	case "day":
-		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		end = start.Add(24 * time.Hour)
-	case "week":
-		weekday := int(now.Weekday())
-		if weekday == 0 {
-			weekday = 7
-		}
-		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -(weekday - 1))
-		end = start.AddDate(0, 0, 7)
-	case "month":
-		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		end = start.AddDate(0, 1, 0)
+func renderTags(s *Store, tags []string) string {
+	var out string
+	for _, tag := range tags {
+		out += tagStyleFor(s, tag).Render(tag)
 	}
+	return out
+}
 
-	tagHours := make(map[string]int)
-	untaggedHours := 0
+var hexColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
 
-	// Calculate from ranges with tags first
-	for _, r := range s.Ranges {
-		rStart := time.Unix(r.Start, 0)
-		rEnd := time.Unix(r.End, 0)
+func hexColor(c string) (r, g, b int, ok bool) {
+	if !hexColorRe.MatchString(c) {
+		return 0, 0, 0, false
+	}
+	v, _ := strconv.ParseUint(c[1:], 16, 32)
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), true
+}
 
-		if r.Status != 1 || rEnd.Before(start) || !rStart.Before(end) {
-			continue
-		}
+// validColor accepts #RRGGBB and ANSI color numbers 0-255.
+func validColor(c string) bool {
+	if n, err := strconv.Atoi(c); err == nil {
+		return n >= 0 && n <= 255
+	}
+	return hexColorRe.MatchString(c)
+}
 
-		// Calculate overlap with period
-		actualStart := rStart
-		if actualStart.Before(start) {
-			actualStart = start
+// renameTag moves every use of from, including its subtags, to to. Ranges
+// that end up with a tag twice keep it once. It returns the changed ranges.
+func renameTag(s *Store, from, to string) int {
+	rename := func(tag string) string {
+		if tagMatches(tag, from) {
+			return to + strings.TrimPrefix(tag, from)
 		}
-		actualEnd := rEnd
-		if actualEnd.After(end) {
-			actualEnd = end
+		return tag
+	}
+	renameAll := func(tags []string) []string {
+		out := make([]string, len(tags))
+		for i, tag := range tags {
+			out[i] = rename(tag)
 		}
-
-		mins := int(actualEnd.Sub(actualStart).Minutes())
-		if mins > 0 {
-			if r.Tag != "" {
-				tagHours[r.Tag] += mins
-			} else {
-				untaggedHours += mins
+		return parseTags(joinTags(out))
+	}
+	renameRanges := func(ranges []Range) (n int) {
+		for i, r := range ranges {
+			if tags := renameAll(r.Tags); !slices.Equal(tags, r.Tags) {
+				ranges[i].Tags = tags
+				n++
 			}
 		}
+		return n
 	}
-
-	// Add untagged working time from bins
-	bins := fetchBins(s, start, end)
-	for t, v := range bins {
-		if v == 1 {
-			// Check if this time is already covered by a tagged range
-			covered := false
-			for _, r := range s.Ranges {
-				rStart := time.Unix(r.Start, 0)
-				rEnd := time.Unix(r.End, 0)
-				if !t.Before(rStart) && t.Before(rEnd) {
-					covered = true
-					break
-				}
-			}
-			if !covered {
-				untaggedHours += binMinutes
-			}
+	n := renameRanges(s.Ranges)
+	// history matches ranges by value, so earlier changes stay undoable
+	for _, c := range s.History {
+		renameRanges(c.Removed)
+		renameRanges(c.Added)
+	}
+	for i, mt := range s.Meetings {
+		s.Meetings[i].Tag = joinTags(renameAll(parseTags(mt.Tag)))
+	}
+	for i, wr := range s.Config.WindowRules {
+		s.Config.WindowRules[i].Tag = joinTags(renameAll(parseTags(wr.Tag)))
+	}
+	now := time.Now()
+	var tags []string
+	for _, tag := range s.Tags {
+		if renamed := rename(tag); renamed != tag {
+			markRemoved(s, "tag", tag, now)
+			tag = renamed
+		}
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
 		}
 	}
-
-	if untaggedHours > 0 {
-		tagHours["(untagged)"] = untaggedHours
+	sort.Strings(tags)
+	s.Tags = tags
+	for tag, meta := range s.TagMeta {
+		if renamed := rename(tag); renamed != tag {
+			delete(s.TagMeta, tag)
+			markRemoved(s, "tagmeta", tag, now)
+			if _, ok := s.TagMeta[renamed]; !ok {
+				meta.Modified = now.Unix()
+				s.TagMeta[renamed] = meta
+			}
+		}
 	}
-
-	return tagHours
+	return n
 }
 
-func createTagAnalyticsBox(s *Store, width int) string {
-	content := "🏷️  TAG ANALYTICS\n\n"
-
-	dayTags := calculateTagHours(s, "day")
-	weekTags := calculateTagHours(s, "week")
-	monthTags := calculateTagHours(s, "month")
-
-	// Collect all unique tags and sort them consistently
-	allTagsMap := make(map[string]bool)
-	for tag := range dayTags {
-		allTagsMap[tag] = true
+func cmdTags(file string, args []string) error {
+	usage := fmt.Errorf("usage: tags list [--all] | rename OLD NEW | merge TAG... INTO | archive TAG [--undo] | set-color TAG COLOR|none")
+	if len(args) == 0 {
+		return usage
 	}
-	for tag := range weekTags {
-		allTagsMap[tag] = true
-	}
-	for tag := range monthTags {
-		allTagsMap[tag] = true
+	fs, path := newCommandFlags("tags "+args[0], file)
+	all := fs.Bool("all", false, "list archived tags too")
+	undo := fs.Bool("undo", false, "unarchive the tag")
+	names := parseArgs(fs, args[1:])
+	for i := range names {
+		names[i] = normalizeTag(names[i])
 	}
 
-	if len(allTagsMap) == 0 {
-		content += "No tagged time recorded"
-		return content
+	store, err := loadStore(*path)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
 	}
-
-	// Convert to sorted slice for consistent ordering
-	var allTags []string
-	for tag := range allTagsMap {
-		allTags = append(allTags, tag)
+	known := knownTags(store)
+	if store.TagMeta == nil {
+		store.TagMeta = map[string]TagMeta{}
 	}
-	sort.Strings(allTags)
 
-	// Always put (untagged) at the end if it exists
-	for i, tag := range allTags {
-		if tag == "(untagged)" {
-			// Move to end
-			allTags = append(allTags[:i], allTags[i+1:]...)
-			allTags = append(allTags, "(untagged)")
-			break
+	switch args[0] {
+	case "list":
+		uses := map[string]int{}
+		last := map[string]int64{}
+		for _, r := range store.Ranges {
+			for _, tag := range r.Tags {
+				uses[tag]++
+				last[tag] = max(last[tag], r.End)
+			}
 		}
-	}
-
-	for _, tag := range allTags {
-		dayHrs := dayTags[tag]
-		weekHrs := weekTags[tag]
-		monthHrs := monthTags[tag]
-
-		if tag == "(untagged)" {
-			content += fmt.Sprintf("%s\n", idleStyle.Render(tag))
+		fmt.Printf("%-30s | %6s | %-10s | %s\n", "Tag", "Uses", "Last used", "Color")
+		fmt.Println(strings.Repeat("-", 65))
+		for _, tag := range known {
+			meta := store.TagMeta[tag]
+			if meta.Archived && !*all {
+				continue
+			}
+			lastUsed := "never"
+			if last[tag] > 0 {
+				lastUsed = time.Unix(last[tag], 0).Format("2006-01-02")
+			}
+			color := meta.Color
+			if meta.Archived {
+				color = strings.TrimSpace(color + " (archived)")
+			}
+			fmt.Printf("%-30s | %6d | %-10s | %s\n", tag, uses[tag], lastUsed, color)
+		}
+		return nil
+	case "rename", "merge":
+		if len(names) < 2 || args[0] == "rename" && len(names) != 2 {
+			return usage
+		}
+		into := names[len(names)-1]
+		if into == "" {
+			return fmt.Errorf("%s: empty target tag", args[0])
+		}
+		if args[0] == "rename" && contains(known, into) {
+			return fmt.Errorf("tag %q already exists, use tags merge", into)
+		}
+		before := cloneRanges(store.Ranges)
+		n := 0
+		for _, from := range names[:len(names)-1] {
+			if !slices.ContainsFunc(known, func(tag string) bool { return tagMatches(tag, from) }) {
+				return fmt.Errorf("unknown tag %q", from)
+			}
+			if from == into || tagMatches(into, from) {
+				return fmt.Errorf("cannot %s %q into itself", args[0], from)
+			}
+			n += renameTag(store, from, into)
+		}
+		mergeRanges(store)
+		recordChange(store, fmt.Sprintf("%s %s to %s", args[0], strings.Join(names[:len(names)-1], ", "), into), before)
+		fmt.Printf("Retagged %d range(s) as %s\n", n, into)
+		// the rules file is edited by hand, so only point at what still uses the old tags
+		if rules, err := loadRules(rulesPath(*path), Config{}); err == nil {
+			olds := names[:len(names)-1]
+			for _, r := range rules {
+				if slices.ContainsFunc(parseTags(r.Tag), func(tag string) bool {
+					return slices.ContainsFunc(olds, func(from string) bool { return tagMatches(tag, from) })
+				}) {
+					fmt.Printf("Note: rule %q in %s still tags %s\n", r.Name, rulesPath(*path), r.Tag)
+				}
+			}
+		}
+	case "archive":
+		if len(names) != 1 {
+			return usage
+		}
+		if !contains(known, names[0]) {
+			return fmt.Errorf("unknown tag %q", names[0])
+		}
+		meta := store.TagMeta[names[0]]
+		meta.Archived, meta.Modified = !*undo, time.Now().Unix()
+		store.TagMeta[names[0]] = meta
+		if *undo {
+			fmt.Println("Unarchived", names[0])
 		} else {
-			content += fmt.Sprintf("%s\n", tagStyle.Render(tag))
+			fmt.Println("Archived", names[0])
+		}
+	case "set-color":
+		if len(names) != 2 {
+			return usage
 		}
-		content += fmt.Sprintf("  Day: %s | Week: %s | Month: %s\n\n",
-			workingStyle.Render(humanDuration(dayHrs)),
-			workingStyle.Render(humanDuration(weekHrs)),
-			workingStyle.Render(humanDuration(monthHrs)))
+		color := names[1]
+		if color == "none" {
+			color = ""
+		} else if !validColor(color) {
+			return fmt.Errorf("invalid color %q, use #RRGGBB or 0-255", color)
+		}
+		meta := store.TagMeta[names[0]]
+		meta.Color, meta.Modified = color, time.Now().Unix()
+		store.TagMeta[names[0]] = meta
+		fmt.Printf("%s\n", tagStyleFor(store, names[0]).Render(names[0]))
+	default:
+		return usage
 	}
-
-	return content
+	for tag, meta := range store.TagMeta {
+		if meta.Color == "" && !meta.Archived {
+			delete(store.TagMeta, tag)
+			markRemoved(store, "tagmeta", tag, time.Now())
+		}
+	}
+	if err := saveStore(*path, store); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	return nil
 }
 
 func main() {
 	reportFlag := flag.Bool("report", false, "print report and exit")
-	rng := flag.String("range", "today", "report range: today|week|month|year")
+	rng := flag.String("range", "today", "report range: today|week|month|year|last-week|last-month, or Nd for the last N days with --by")
+	by := flag.String("by", "", "group the report by: repo|hour|focus")
+	tagFilter := flag.String("tag", "", "limit the report to a tag and its subtags, or a query like 'client:acme AND type:meeting'")
+	device := flag.String("device", "", "limit the report to the time tracked on one machine of a merged store")
 	file := flag.String("file", defaultFile, "path to JSON store")
-	configFlag := flag.String("config", "", "config in format key=value (e.g., dailygoal=07:30 or workdays=Mon-Fri)")
+	configFlag := flag.String("config", "", "config in format key=value (e.g., dailygoal=07:30, workdays=Mon-Fri or windowrule=coding=Code)")
 	dashboardFlag := flag.Bool("dashboard", false, "show interactive dashboard")
 
 	flag.Parse()
@@ -1594,6 +7875,152 @@ func main() {
 				os.Exit(1)
 			}
 			store.Config.WorkDays = days
+		case "windowrule":
+			// windowrule=TAG=APP_REGEX
+			rule := strings.SplitN(parts[1], "=", 2)
+			if len(rule) != 2 || rule[0] == "" {
+				fmt.Fprintln(os.Stderr, "Invalid window rule, use windowrule=TAG=APP_REGEX")
+				os.Exit(1)
+			}
+			if _, err := regexp.Compile(rule[1]); err != nil {
+				fmt.Fprintln(os.Stderr, "Invalid window rule regex:", err)
+				os.Exit(1)
+			}
+			store.Config.WindowRules = append(store.Config.WindowRules, WindowRule{App: rule[1], Tag: rule[0]})
+		case "windowcmd":
+			store.Config.WindowCommand = parts[1]
+		case "meetings":
+			if parts[1] != "working" && parts[1] != "separate" {
+				fmt.Fprintln(os.Stderr, "Invalid meetings mode, use working or separate")
+				os.Exit(1)
+			}
+			store.Config.ShowMeetings = parts[1] == "separate"
+		case "balancestart":
+			day, err := parseDateArg(parts[1], false)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			store.Config.BalanceStart = day.Format("2006-01-02")
+		case "gittagby":
+			if parts[1] != "repo" && parts[1] != "branch" {
+				fmt.Fprintln(os.Stderr, "Invalid gittagby, use repo or branch")
+				os.Exit(1)
+			}
+			store.Config.GitTagBy = parts[1]
+		case "gitwindow":
+			mins, err := strconv.Atoi(parts[1])
+			if err != nil || mins <= 0 {
+				fmt.Fprintln(os.Stderr, "Invalid gitwindow, use a number of minutes")
+				os.Exit(1)
+			}
+			store.Config.GitWindowMinutes = mins
+		case "focusgap", "focusmin", "deepwork":
+			mins, err := parseTimeToMinutes(parts[1])
+			if err != nil || mins < 0 || mins == 0 && parts[0] != "focusgap" {
+				fmt.Fprintf(os.Stderr, "Invalid %s, use HH:MM\n", parts[0])
+				os.Exit(1)
+			}
+			switch parts[0] {
+			case "focusgap":
+				store.Config.FocusGapMinutes = mins
+			case "focusmin":
+				store.Config.MinSessionMinutes = mins
+			default:
+				store.Config.DeepWorkMinutes = mins
+			}
+		case "tagallocation":
+			if parts[1] != "full" && parts[1] != "split" {
+				fmt.Fprintln(os.Stderr, "Invalid tagallocation, use full or split")
+				os.Exit(1)
+			}
+			store.Config.TagAllocation = parts[1]
+		case "pomodoro", "shortbreak", "longbreak", "breakreminder":
+			mins, err := parseTimeToMinutes(parts[1])
+			if err != nil || mins < 0 || mins == 0 && parts[0] != "breakreminder" {
+				fmt.Fprintf(os.Stderr, "Invalid %s, use HH:MM\n", parts[0])
+				os.Exit(1)
+			}
+			switch parts[0] {
+			case "pomodoro":
+				store.Config.PomodoroMinutes = mins
+			case "shortbreak":
+				store.Config.ShortBreakMinutes = mins
+			case "longbreak":
+				store.Config.LongBreakMinutes = mins
+			default:
+				store.Config.BreakReminder = mins
+			}
+		case "longbreakevery":
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, "Invalid longbreakevery, use a number of pomodoros")
+				os.Exit(1)
+			}
+			store.Config.LongBreakEvery = n
+		case "notifycmd":
+			store.Config.NotifyCommand = parts[1]
+		case "notifysinks":
+			store.Config.NotifySinks = nil
+			for _, sink := range strings.Split(parts[1], ",") {
+				switch sink = strings.TrimSpace(sink); sink {
+				case "desktop", "stdout", "command":
+					store.Config.NotifySinks = append(store.Config.NotifySinks, sink)
+				case "none":
+					store.Config.NotifySinks = []string{"none"}
+				default:
+					fmt.Fprintln(os.Stderr, "Invalid notify sink, use desktop, stdout, command or none")
+					os.Exit(1)
+				}
+			}
+		case "quiethours", "endofday":
+			times := strings.Split(parts[1], "-")
+			if parts[1] == "off" {
+				times, parts[1] = nil, ""
+			} else if parts[0] == "endofday" && len(times) != 1 || parts[0] == "quiethours" && len(times) != 2 {
+				fmt.Fprintf(os.Stderr, "Invalid %s, use %s\n", parts[0], map[string]string{"endofday": "HH:MM", "quiethours": "HH:MM-HH:MM"}[parts[0]])
+				os.Exit(1)
+			}
+			for _, t := range times {
+				if _, err := parseTimeToMinutes(t); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid %s: %v\n", parts[0], err)
+					os.Exit(1)
+				}
+			}
+			if parts[0] == "endofday" {
+				store.Config.EndOfDay = parts[1]
+			} else {
+				store.Config.QuietHours = parts[1]
+			}
+		case "idlefailures":
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, "Invalid idlefailures, use a number of samples")
+				os.Exit(1)
+			}
+			store.Config.IdleFailures = n
+		case "device":
+			if parts[1] == "" || strings.ContainsAny(parts[1], `/\`) {
+				fmt.Fprintln(os.Stderr, "Invalid device, use a name without slashes")
+				os.Exit(1)
+			}
+			store.Config.Device = parts[1]
+		case "syncdir":
+			store.Config.SyncDir = parts[1]
+		case "heatmapdays":
+			days, err := strconv.Atoi(parts[1])
+			if err != nil || days <= 0 {
+				fmt.Fprintln(os.Stderr, "Invalid heatmapdays, use a number of days")
+				os.Exit(1)
+			}
+			store.Config.HeatmapDays = days
+		case "windowdays":
+			days, err := strconv.Atoi(parts[1])
+			if err != nil || days <= 0 {
+				fmt.Fprintln(os.Stderr, "Invalid windowdays, use a number of days")
+				os.Exit(1)
+			}
+			store.Config.WindowDays = days
 		default:
 			fmt.Fprintln(os.Stderr, "Unknown config key:", parts[0])
 			os.Exit(1)
@@ -1607,6 +8034,14 @@ func main() {
 		return
 	}
 
+	if flag.NArg() > 0 {
+		if err := runCommand(*file, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if execPath, err := os.Executable(); err == nil {
 		ensureStartupAtLogin(execPath)
 	}
@@ -1628,8 +8063,10 @@ func main() {
 		m := dashboardModel{
 			store:    store,
 			filePath: *file,
+			anchor:   -1,
 		}
 		m.buildTimelineBlocks()
+		m.refreshBalance()
 		p := tea.NewProgram(m, tea.WithAltScreen())
 		if _, err := p.Run(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error running dashboard: %v\n", err)
@@ -1639,15 +8076,40 @@ func main() {
 	}
 
 	if *reportFlag {
-		report(store, *rng)
+		if *device != "" {
+			if store, err = filterDevice(store, *device); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		report(store, *rng, *by, *tagFilter)
 		return
 	}
 
 	fmt.Println("[timetracking] Tracking started. Ctrl+C to stop.")
+	var notes notifier
+	var wasWorking bool
+	var lastTags []string
+	var lastSync time.Time
+	var compactedDay string
+	rulesErr := "<nil>"
+	hook := func(event string, now time.Time) {
+		if len(store.Config.Hooks) > 0 {
+			go fireHooks(store.Config.Hooks, *file, newHookEvent(store, event, now))
+		}
+	}
 	for {
 		now := time.Now()
 		currentBin := floorToBin(now)
-		if la, err := lastActivity(now); err == nil {
+		la, err := lastActivity(now)
+		if err != nil {
+			if freshStore, err := loadStore(*file); err == nil {
+				store = freshStore
+			}
+			if notes.check(store, now, err) {
+				_ = saveStore(*file, store)
+			}
+		} else {
 			working := !la.Before(currentBin) // last activity >= bin start
 
 			// Always reload store before saving to preserve dashboard changes
@@ -1655,13 +8117,62 @@ func main() {
 				store = freshStore
 			}
 
-			upsertBin(store, currentBin, working)
+			if upsertBin(store, currentBin, working) && !wasWorking {
+				wasWorking = true
+				hook("working", now)
+			} else if wasWorking && now.Sub(la) >= binMinutes*time.Minute {
+				wasWorking = false
+				hook("idle", now)
+			}
+			if working {
+				ctx := RuleContext{Time: now, Session: sessionAt(store, now)}
+				if w, err := activeWindow(store.Config); err == nil {
+					recordWindow(store, now, w)
+					ctx.App, ctx.Title, ctx.Dir, ctx.Repo = w.App, w.Title, w.Dir, w.Repo
+				}
+				rules, err := loadRules(rulesPath(*file), store.Config)
+				// Report a broken rules file once, not on every sample.
+				if msg := fmt.Sprint(err); msg != rulesErr {
+					rulesErr = msg
+					if err != nil {
+						fmt.Fprintln(os.Stderr, "\nrules:", err)
+					}
+				}
+				if r := matchRules(rules, ctx); r != nil {
+					applyAutoTag(store, currentBin, r.Tag, r.Note, "rule")
+				}
+				if e := gitEventNear(store, now); e != nil {
+					applyAutoTag(store, currentBin, gitTag(store.Config, *e), ticketID(e.Branch), "git")
+				}
+				if tags := binTags(store, currentBin); len(tags) > 0 && !slices.Equal(tags, lastTags) {
+					hook("tag", now)
+				}
+				lastTags = binTags(store, currentBin)
+			}
+			notes.check(store, now, nil)
+			if work, _ := todayTotals(store); dailyGoal(store, now) > 0 && work >= dailyGoal(store, now) &&
+				markOnce(store, "hook-goal:"+now.Format("2006-01-02"), now) {
+				hook("goal", now)
+			}
 			_ = saveStore(*file, store)
 
 			if len(store.Bins) > 100 {
 				compactBins(store)
 				_ = saveStore(*file, store)
 			}
+			if day := now.Format("2006-01-02"); day != compactedDay {
+				compactedDay = day
+				compactWindows(store, now)
+				_ = saveStore(*file, store)
+			}
+		}
+		if store.Config.SyncDir != "" && now.Sub(lastSync) >= syncInterval {
+			lastSync = now
+			if _, err := syncStore(*file, store.Config.SyncDir, false); err != nil {
+				fmt.Fprintln(os.Stderr, "\nsync:", err)
+			} else if freshStore, err := loadStore(*file); err == nil {
+				store = freshStore
+			}
 		}
 		w, i := todayTotals(store)
 		fmt.Printf("[status] working: %s | idle: %s\r", humanDuration(w), humanDuration(i))
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandICSEvent(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name  string
+		start string
+		rrule string
+		want  []string
+	}{
+		{"second tuesday", "2025-01-14 10:00", "FREQ=MONTHLY;BYDAY=2TU;COUNT=4",
+			[]string{"2025-01-14", "2025-02-11", "2025-03-11", "2025-04-08"}},
+		{"last friday", "2025-01-31 09:00", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			[]string{"2025-01-31", "2025-02-28", "2025-03-28"}},
+		{"last day of month", "2025-01-31 09:00", "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			[]string{"2025-01-31", "2025-02-28", "2025-03-31"}},
+		{"last workday", "2025-01-31 09:00", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3",
+			[]string{"2025-01-31", "2025-02-28", "2025-03-31"}},
+		{"day 31 skips short months", "2025-01-31 09:00", "FREQ=MONTHLY;COUNT=3",
+			[]string{"2025-01-31", "2025-03-31", "2025-05-31"}},
+		{"every other month", "2025-01-14 10:00", "FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU;UNTIL=20250601T000000Z",
+			[]string{"2025-01-14", "2025-03-11", "2025-05-13"}},
+		{"friday the 13th", "2025-06-13 12:00", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=2",
+			[]string{"2025-06-13", "2026-02-13"}},
+		// RFC 5545 3.8.5.3: WKST changes which days share a week.
+		{"weekly wkst monday", "1997-08-05 09:00", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			[]string{"1997-08-05", "1997-08-10", "1997-08-19", "1997-08-24"}},
+		{"weekly wkst sunday", "1997-08-05 09:00", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			[]string{"1997-08-05", "1997-08-17", "1997-08-19", "1997-08-31"}},
+		{"daily count", "2025-03-01 08:00", "FREQ=DAILY;COUNT=3",
+			[]string{"2025-03-01", "2025-03-02", "2025-03-03"}},
+		{"yearly", "2024-02-29 08:00", "FREQ=YEARLY;COUNT=2",
+			[]string{"2024-02-29", "2028-02-29"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := day(tt.start)
+			ev := icsEvent{Start: start, End: start.Add(time.Hour), RRule: map[string]string{}}
+			for _, part := range strings.Split(tt.rrule, ";") {
+				k, v, _ := strings.Cut(part, "=")
+				ev.RRule[k] = v
+			}
+			var got []string
+			for _, o := range expandICSEvent(ev, start, start.AddDate(10, 0, 0)) {
+				if o.Hour() != start.Hour() || o.Minute() != start.Minute() {
+					t.Errorf("occurrence %v lost the start time", o)
+				}
+				got = append(got, o.Format("2006-01-02"))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandICSEventExDate(t *testing.T) {
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	ev := icsEvent{Start: start, End: start.Add(time.Hour), RRule: map[string]string{"FREQ": "WEEKLY", "COUNT": "3"},
+		ExDates: []time.Time{start.AddDate(0, 0, 7)}}
+	got := expandICSEvent(ev, start, start.AddDate(1, 0, 0))
+	if len(got) != 2 || !got[1].Equal(start.AddDate(0, 0, 14)) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestParseICSTimeZones(t *testing.T) {
+	got, _, err := parseICSTime("20250314T090000", map[string]string{"TZID": "W. Europe Standard Time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 14, 8, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got.UTC(), want)
+	}
+	if _, _, err := parseICSTime("20250314T090000", map[string]string{"TZID": "Nowhere Standard Time"}); !errors.Is(err, errUnknownZone) {
+		t.Errorf("unknown zone: got %v", err)
+	}
+
+	ics := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nSUMMARY:Known\nDTSTART;TZID=Europe/Berlin:20250314T090000\nDTEND;TZID=Europe/Berlin:20250314T100000\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:b\nSUMMARY:Odd\nDTSTART;TZID=Nowhere:20250314T090000\nDTEND;TZID=Nowhere:20250314T100000\nEND:VEVENT\nEND:VCALENDAR\n"
+	events, skipped, err := parseICS([]byte(ics), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].UID != "a" || len(skipped) != 1 || !strings.Contains(skipped[0], "Odd") {
+		t.Errorf("events %v, skipped %v", events, skipped)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	t.Setenv("TIMETRACK_PASSPHRASE", "correct horse")
+	header, err := newStoreKey('p', []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &Store{Bins: map[string]int{"1700000000": 1}, Tags: []string{"client"}}
+	if err := writeStore(path, in, header); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("client")) {
+		t.Fatal("store written in plain text")
+	}
+
+	forgetStoreKey(header)
+	out, err := loadStore(path)
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	if out.Bins["1700000000"] != 1 || !slices.Equal(out.Tags, in.Tags) {
+		t.Fatalf("round trip: got %+v", out)
+	}
+
+	forgetStoreKey(header)
+	t.Setenv("TIMETRACK_PASSPHRASE", "wrong")
+	if _, err := openStore(path, data); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+
+	t.Setenv("TIMETRACK_PASSPHRASE", "correct horse")
+	for _, n := range []int{storeHeaderLen - 1, storeHeaderLen + 4, len(data) - 1} {
+		forgetStoreKey(header)
+		if _, err := openStore(path, data[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: no error", n)
+		}
+	}
+
+	// The cost is read before the header can be verified, so it is bounded.
+	costly := slices.Clone(data)
+	costly[storeHeaderLen-1] = 40
+	if _, err := openStore(path, costly); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Fatalf("cost 2^40: got %v", err)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		words  []string
+		reason string
+	}{
+		{[]string{"add", "2025-12-24"}, []string{"add", "2025-12-24"}, ""},
+		{[]string{"add", "2025-12-24", "--reason", "xmas"}, []string{"add", "2025-12-24"}, "xmas"},
+		{[]string{"--reason", "xmas", "add", "2025-12-24"}, []string{"add", "2025-12-24"}, "xmas"},
+		{[]string{"add", "2025-12-24", "--reason", "xmas", "2025-12-31"}, []string{"add", "2025-12-24", "2025-12-31"}, "xmas"},
+		{[]string{"a", "--reason=x", "b", "-", "c"}, []string{"a", "b", "-", "c"}, "x"},
+		{[]string{"a", "--", "--reason", "b"}, []string{"a", "--reason", "b"}, ""},
+		{[]string{"--reason", "x", "--", "-b"}, []string{"-b"}, "x"},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		reason := fs.String("reason", "", "")
+		words := parseArgs(fs, tt.args)
+		if !slices.Equal(words, tt.words) || *reason != tt.reason {
+			t.Errorf("parseArgs(%q) = %q, reason %q; want %q, reason %q", tt.args, words, *reason, tt.words, tt.reason)
+		}
+	}
+}
+
+func TestRecordGitEvent(t *testing.T) {
+	at := time.Date(2025, 3, 4, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		kind string
+		want []string // tagged bins
+	}{
+		{"commit", []string{"09:30", "09:35", "09:40", "09:45", "09:50", "09:55", "10:00"}},
+		{"checkout", []string{"10:00", "10:05", "10:10", "10:15", "10:20", "10:25", "10:30"}},
+	}
+	for _, tt := range tests {
+		s := &Store{Bins: map[string]int{}}
+		for b := at.Add(-time.Hour); b.Before(at.Add(time.Hour)); b = b.Add(binMinutes * time.Minute) {
+			s.Bins[strconv.FormatInt(b.Unix(), 10)] = 1
+		}
+		recordGitEvent(s, GitEvent{Time: at.Unix(), Kind: tt.kind, Repo: "app", Branch: "feature/ABC-1"})
+		var got []string
+		for b := at.Add(-time.Hour); b.Before(at.Add(time.Hour)); b = b.Add(binMinutes * time.Minute) {
+			if slices.Equal(binTags(s, b), []string{"app"}) {
+				got = append(got, b.Format("15:04"))
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: tagged %v, want %v", tt.kind, got, tt.want)
+		}
+		if e := gitEventNear(s, at.Add(-10*time.Minute)); (e != nil) != (tt.kind == "commit") {
+			t.Errorf("%s: event near the time before it = %v", tt.kind, e)
+		}
+	}
+}
+
+func TestBinIndex(t *testing.T) {
+	day := time.Date(2025, 3, 4, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) int64 { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute).Unix() }
+	s := &Store{
+		Ranges: []Range{
+			{Start: at(8, 0), End: at(9, 0), Status: 1},
+			{Start: at(8, 30), End: at(10, 0), TagOnly: true, Tags: []string{"auto"}, Source: "rule"},
+			{Start: at(9, 0), End: at(9, 30), TagOnly: true, Tags: []string{"manual"}},
+			{Start: at(9, 10), End: at(9, 20), TagOnly: true, Tags: []string{"later"}},
+			{Start: at(9, 2), End: at(9, 12), Override: true},
+			{Start: at(9, 5), End: at(9, 40), Override: true, Status: 1},
+			{Start: at(9, 15), End: at(9, 15), Split: true},
+			{Start: at(23, 0), End: at(25, 0), TagOnly: true, Tags: []string{"overnight"}},
+		},
+		Meetings: []Meeting{
+			{Start: at(10, 2), End: at(10, 33), Tag: "meeting"},
+			{Start: at(10, 20), End: at(11, 0), Tag: "other"},
+		},
+	}
+	ix := indexBins(s, day, day.AddDate(0, 0, 1))
+	for b := day; b.Before(day.AddDate(0, 0, 1)); b = b.Add(binMinutes * time.Minute) {
+		if got, want := ix.tagRangeAt(b), tagRangeAt(s, b); got != want {
+			t.Errorf("%s: tag range %d, want %d", b.Format("15:04"), got, want)
+		}
+		if got, want := ix.overrideAt(b), overrideAt(s, b); got != want {
+			t.Errorf("%s: override %d, want %d", b.Format("15:04"), got, want)
+		}
+		if got, want := ix.splitAt(b), splitAt(s, b.Unix()); got != want {
+			t.Errorf("%s: split %v, want %v", b.Format("15:04"), got, want)
+		}
+		if got, want := ix.meetingAt(b), meetingAt(s, b); got != want {
+			t.Errorf("%s: meeting %v, want %v", b.Format("15:04"), got, want)
+		}
+	}
+}
+
+func TestFocusSessions(t *testing.T) {
+	day := time.Date(2025, 3, 4, 9, 0, 0, 0, time.Local)
+	// one character per 5-minute bin from 09:00, # working
+	const pattern = "######.######..###" + "............" + "##"
+	tests := []struct {
+		name      string
+		gap       int
+		sessions  []string // start-end
+		fragments int
+	}{
+		{"no gap bridged by default", 0, []string{"09:00-09:30", "09:35-10:05", "10:15-10:30"}, 1},
+		{"gap below one bin", 4, []string{"09:00-09:30", "09:35-10:05", "10:15-10:30"}, 1},
+		{"one idle bin", 10, []string{"09:00-10:05", "10:15-10:30"}, 1},
+		{"two idle bins", 15, []string{"09:00-10:30"}, 1},
+		{"whole break", 65, []string{"09:00-11:40"}, 0},
+	}
+	for _, tt := range tests {
+		s := &Store{Bins: map[string]int{}, Config: Config{FocusGapMinutes: tt.gap}}
+		for i, c := range pattern {
+			if c == '#' {
+				s.Bins[strconv.FormatInt(day.Add(time.Duration(i*binMinutes)*time.Minute).Unix(), 10)] = 1
+			}
+		}
+		sessions, fragments := focusSessions(s, day, day.Add(3*time.Hour))
+		var got []string
+		for _, f := range sessions {
+			got = append(got, f.Start.Format("15:04")+"-"+f.End.Format("15:04"))
+		}
+		if !slices.Equal(got, tt.sessions) || fragments != tt.fragments {
+			t.Errorf("%s: sessions %v, %d fragments; want %v, %d", tt.name, got, fragments, tt.sessions, tt.fragments)
+		}
+	}
+}
+
+func TestNotifierQuietHours(t *testing.T) {
+	night := time.Date(2025, 3, 4, 23, 0, 0, 0, time.Local)
+	morning := night.Add(9 * time.Hour)
+	tests := []struct {
+		name    string
+		idleErr error
+	}{
+		{"sample", nil},
+		{"idle error", errors.New("no idle source")},
+	}
+	for _, tt := range tests {
+		s := &Store{
+			Config:   Config{QuietHours: "22:00-07:00", NotifySinks: []string{"none"}, IdleFailures: 1},
+			Notified: map[string]int64{"idle:2025-03-05": morning.Unix()}, // already told this morning
+		}
+		var n notifier
+		if n.send(s, night, "goal:2025-03-04", "Daily goal reached", "8 hrs worked today") {
+			t.Errorf("%s: sent during quiet hours", tt.name)
+		}
+		if n.check(s, night.Add(time.Hour), tt.idleErr) {
+			t.Errorf("%s: check during quiet hours reported a change", tt.name)
+		}
+		// the next day's check sends it and must report the store changed
+		if !n.check(s, morning, tt.idleErr) {
+			t.Errorf("%s: check after quiet hours reported no change", tt.name)
+		}
+		if _, ok := s.Notified["goal:2025-03-04"]; !ok || len(n.held) != 0 {
+			t.Errorf("%s: held notifications not sent after quiet hours", tt.name)
+		}
+	}
+}
+
+func TestSyncRemovals(t *testing.T) {
+	dir := t.TempDir()
+	syncDir := filepath.Join(dir, "sync")
+	a, b := filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")
+	old := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local).Unix()
+	meeting := Meeting{Calendar: "work", UID: "standup", Start: old + 3600, End: old + 5400, Modified: old}
+	if err := saveStore(a, &Store{
+		Config:   Config{Device: "a"},
+		Tags:     []string{"x", "y"},
+		TagMeta:  map[string]TagMeta{"x": {Color: "#ff0000", Modified: old}},
+		DaysOff:  []DayOff{{Date: "2025-03-10", Modified: old}, {Date: "2025-03-11", Modified: old}},
+		Meetings: []Meeting{meeting},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveStore(b, &Store{Config: Config{Device: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{a, b} {
+		if _, err := syncStore(path, syncDir, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b removes a day off, the tag color and renames a tag, and the meeting moves
+	for _, args := range [][]string{{"off", "remove", "2025-03-10"}, {"tags", "set-color", "x", "none"}, {"tags", "rename", "y", "z"}} {
+		cmd := cmdOff
+		if args[0] == "tags" {
+			cmd = cmdTags
+		}
+		if err := cmd(b, args[1:]); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	s, err := loadStore(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := meeting
+	moved.Start, moved.End, moved.Modified = meeting.Start+1800, meeting.End+1800, time.Now().Unix()
+	s.Meetings = []Meeting{moved}
+	markRemoved(s, "meeting", meetingKey(meeting), time.Now())
+	if err := saveStore(b, s); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{b, a} {
+		if _, err := syncStore(path, syncDir, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err = loadStore(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.DaysOff) != 1 || s.DaysOff[0].Date != "2025-03-11" {
+		t.Errorf("days off = %+v, want only 2025-03-11", s.DaysOff)
+	}
+	if len(s.Meetings) != 1 || s.Meetings[0].Start != moved.Start {
+		t.Errorf("meetings = %+v, want only the moved one", s.Meetings)
+	}
+	if meta, ok := s.TagMeta["x"]; ok {
+		t.Errorf("tag x still has %+v", meta)
+	}
+	if !slices.Equal(s.Tags, []string{"x", "z"}) {
+		t.Errorf("tags = %v, want [x z]", s.Tags)
+	}
+}
+
+func TestMergeStoresTags(t *testing.T) {
+	t0 := time.Date(2025, 3, 3, 9, 0, 0, 0, time.Local)
+	hour := func(tags string, source string, modified int64) Range {
+		return Range{Start: t0.Unix(), End: t0.Add(time.Hour).Unix(), Status: 1, Tags: parseTags(tags),
+			Source: source, TagOnly: true, Modified: modified}
+	}
+	cleared := func(modified int64) Range {
+		return Range{Start: t0.Unix(), End: t0.Add(30 * time.Minute).Unix(), TagOnly: true, Modified: modified}
+	}
+	tests := []struct {
+		name        string
+		a, b        []Range
+		cleared     []Range // of b
+		first, last string  // tags at 9:00 and at 9:30
+	}{
+		{"newer edit wins", []Range{hour("a", "", 100)}, []Range{hour("b", "", 200)}, nil, "b", "b"},
+		{"older edit loses", []Range{hour("a", "", 300)}, []Range{hour("b", "", 200)}, nil, "a", "a"},
+		{"manual beats rule", []Range{hour("a", "", 100)}, []Range{hour("b", "rule", 200)}, nil, "a", "a"},
+		{"newer clear wins", []Range{hour("a", "", 100)}, nil, []Range{cleared(200)}, "", "a"},
+		{"tag after clear wins", []Range{hour("a", "", 300)}, nil, []Range{cleared(200)}, "a", "a"},
+	}
+	for _, tt := range tests {
+		a := &Store{Config: Config{Device: "a"}, Ranges: tt.a}
+		b := &Store{Config: Config{Device: "b"}, Ranges: tt.b, Cleared: tt.cleared}
+		for _, order := range [][]*Store{{a, b}, {b, a}} {
+			s := mergeStores(order)
+			tagsAt := func(at time.Time) string {
+				if i := tagRangeAt(s, at); i >= 0 {
+					return strings.Join(s.Ranges[i].Tags, ",")
+				}
+				return ""
+			}
+			if got := tagsAt(t0); got != tt.first {
+				t.Errorf("%s: merged into %s: tags at 9:00 = %q, want %q", tt.name, order[0].Config.Device, got, tt.first)
+			}
+			if got := tagsAt(t0.Add(30 * time.Minute)); got != tt.last {
+				t.Errorf("%s: merged into %s: tags at 9:30 = %q, want %q", tt.name, order[0].Config.Device, got, tt.last)
+			}
+		}
+	}
+}
+
+func TestMergeStoresByIdentity(t *testing.T) {
+	a := &Store{
+		Config:   Config{Device: "a"},
+		Sessions: []Session{{Name: "focus", Start: 100}, {Name: "focus", Start: 500, End: 600}},
+		Meetings: []Meeting{{Calendar: "work", UID: "m", Start: 1000, End: 2000, Summary: "old", Modified: 10}},
+		DaysOff:  []DayOff{{Date: "2025-03-10", Reason: "vacation", Modified: 20}},
+		TagMeta:  map[string]TagMeta{"x": {Color: "1", Modified: 10}},
+	}
+	b := &Store{
+		Config:   Config{Device: "b"},
+		Sessions: []Session{{Name: "focus", Start: 100, End: 200}},
+		Meetings: []Meeting{
+			{Calendar: "work", UID: "m", Start: 1000, End: 2000, Summary: "new", Modified: 20},
+			{Calendar: "work", UID: "m", Start: 3000, End: 4000, Summary: "next", Modified: 20},
+		},
+		DaysOff: []DayOff{{Date: "2025-03-10", Reason: "sick", Modified: 10}},
+		TagMeta: map[string]TagMeta{"x": {Color: "2", Modified: 20}},
+	}
+	for _, order := range [][]*Store{{a, b}, {b, a}} {
+		s := mergeStores(order)
+		into := order[0].Config.Device
+		slices.SortFunc(s.Sessions, func(a, b Session) int { return int(a.Start - b.Start) })
+		if want := []Session{{Name: "focus", Start: 100, End: 200}, {Name: "focus", Start: 500, End: 600}}; !slices.Equal(s.Sessions, want) {
+			t.Errorf("into %s: sessions = %+v, want %+v", into, s.Sessions, want)
+		}
+		if len(s.Meetings) != 2 || s.Meetings[0].Summary != "new" || s.Meetings[1].Summary != "next" {
+			t.Errorf("into %s: meetings = %+v, want new and next", into, s.Meetings)
+		}
+		if len(s.DaysOff) != 1 || s.DaysOff[0].Reason != "vacation" {
+			t.Errorf("into %s: days off = %+v, want vacation only", into, s.DaysOff)
+		}
+		if s.TagMeta["x"].Color != "2" {
+			t.Errorf("into %s: tag x color = %q, want 2", into, s.TagMeta["x"].Color)
+		}
+	}
+}
+
+func TestUndoRedoMergedRanges(t *testing.T) {
+	t0 := time.Date(2025, 3, 3, 9, 0, 0, 0, time.Local).Unix()
+	tag := func(start, end int64, tags string) Range {
+		return Range{Start: t0 + start*60, End: t0 + end*60, Status: 1, Tags: parseTags(tags), TagOnly: true}
+	}
+	tests := []struct {
+		name  string
+		edit  func(s *Store)
+		after []Range
+	}{
+		{"joined with a neighbour", func(s *Store) {
+			s.Ranges = append(s.Ranges, tag(60, 90, "a"))
+		}, []Range{tag(0, 90, "a")}},
+		{"cleared in the middle", func(s *Store) {
+			clearTags(s, t0+20*60, t0+40*60)
+		}, []Range{tag(0, 20, "a"), tag(40, 60, "a")}},
+		{"retagged in the middle", func(s *Store) {
+			cutTagRanges(s, t0+20*60, t0+40*60, func(Range) bool { return true })
+			s.Ranges = append(s.Ranges, tag(20, 40, "b"))
+		}, []Range{tag(0, 20, "a"), tag(20, 40, "b"), tag(40, 60, "a")}},
+	}
+	// Modified is stamped with the current time, so leave it out.
+	spans := func(rs []Range) []Range {
+		rs = slices.Clone(rs)
+		for i := range rs {
+			rs[i].Modified = 0
+		}
+		slices.SortFunc(rs, func(a, b Range) int { return int(a.Start - b.Start) })
+		return rs
+	}
+	equal := func(a, b []Range) bool {
+		return slices.EqualFunc(spans(a), spans(b), func(x, y Range) bool { return rangeKey(x) == rangeKey(y) })
+	}
+	for _, tt := range tests {
+		original := []Range{tag(0, 60, "a")}
+		s := &Store{Ranges: cloneRanges(original)}
+		before := cloneRanges(s.Ranges)
+		tt.edit(s)
+		mergeRanges(s)
+		recordChange(s, tt.name, before)
+		if !equal(s.Ranges, tt.after) {
+			t.Fatalf("%s: edited ranges = %+v, want %+v", tt.name, s.Ranges, tt.after)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := undoChange(s); err != nil {
+				t.Fatalf("%s: undo: %v", tt.name, err)
+			}
+			if !equal(s.Ranges, original) {
+				t.Errorf("%s: after undo ranges = %+v, want %+v", tt.name, s.Ranges, original)
+			}
+			if _, err := redoChange(s); err != nil {
+				t.Fatalf("%s: redo: %v", tt.name, err)
+			}
+			if !equal(s.Ranges, tt.after) {
+				t.Errorf("%s: after redo ranges = %+v, want %+v", tt.name, s.Ranges, tt.after)
+			}
+		}
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		tags     string // of the range, the window rule, the history and the meeting
+		want     string
+		renamed  int
+	}{
+		{"plain", "work", "client", "work", "client", 1},
+		{"children follow", "work", "client", "work/api,home", "client/api,home", 1},
+		{"prefix of another tag", "work", "client", "workshop", "workshop", 0},
+		{"merge into an existing tag", "work", "home", "work,home", "home", 1},
+	}
+	for _, tt := range tests {
+		r := Range{Start: 0, End: 300, Status: 1, Tags: parseTags(tt.tags), TagOnly: true}
+		s := &Store{
+			Ranges:   []Range{r},
+			History:  []Change{{Action: "tag", Added: []Range{r}}},
+			Meetings: []Meeting{{Calendar: "work", Start: 0, End: 300, Tag: tt.tags}},
+			Config:   Config{WindowRules: []WindowRule{{App: "code", Tag: tt.tags}}},
+			Tags:     parseTags(tt.tags),
+			TagMeta:  map[string]TagMeta{tt.from: {Color: "1"}},
+		}
+		if n := renameTag(s, tt.from, tt.to); n != tt.renamed {
+			t.Errorf("%s: renamed %d ranges, want %d", tt.name, n, tt.renamed)
+		}
+		// known tags are kept sorted, so compare sets
+		sorted := func(tags []string) string { return joinTags(slices.Sorted(slices.Values(tags))) }
+		for what, got := range map[string][]string{
+			"range":       s.Ranges[0].Tags,
+			"history":     s.History[0].Added[0].Tags,
+			"meeting":     parseTags(s.Meetings[0].Tag),
+			"window rule": parseTags(s.Config.WindowRules[0].Tag),
+			"known tags":  s.Tags,
+		} {
+			if want := sorted(parseTags(tt.want)); sorted(got) != want {
+				t.Errorf("%s: %s tags = %q, want %q", tt.name, what, joinTags(got), want)
+			}
+		}
+		if _, ok := s.TagMeta[tt.to]; !ok {
+			t.Errorf("%s: tag settings not moved to %s", tt.name, tt.to)
+		}
+	}
+}
+
+func TestPeriodGoal(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	weekdays := func(minutes int, days ...string) map[string]int {
+		m := map[string]int{}
+		for _, d := range days {
+			m[d] = minutes
+		}
+		return m
+	}
+	workweek := weekdays(480, "mon", "tue", "wed", "thu", "fri")
+	tests := []struct {
+		name       string
+		schedules  []Schedule
+		daysOff    []DayOff
+		start, end string
+		want       int
+	}{
+		{"default daily goals", nil, nil, "2025-03-03", "2025-03-10", 5 * 480},
+		{"weekday schedule", []Schedule{{From: "2025-01-01", Weekdays: weekdays(540, "mon", "tue", "wed", "thu")}},
+			nil, "2025-03-03", "2025-03-10", 4 * 540},
+		{"weekend schedule", []Schedule{{From: "2025-01-01", Weekdays: weekdays(300, "sat")}},
+			nil, "2025-03-03", "2025-03-10", 300},
+		{"weekly target", []Schedule{{From: "2025-01-01", Weekdays: workweek, WeeklyMinutes: 2000}},
+			nil, "2025-03-03", "2025-03-10", 2000},
+		{"weekly target with a day off", []Schedule{{From: "2025-01-01", Weekdays: workweek, WeeklyMinutes: 2000}},
+			[]DayOff{{Date: "2025-03-05"}}, "2025-03-03", "2025-03-10", 1600},
+		{"weekly target with a half day off", []Schedule{{From: "2025-01-01", Weekdays: workweek, WeeklyMinutes: 2000}},
+			[]DayOff{{Date: "2025-03-05", Half: true}}, "2025-03-03", "2025-03-10", 1800},
+		{"weekly target only for whole weeks", []Schedule{{From: "2025-01-01", Weekdays: workweek, WeeklyMinutes: 2000}},
+			nil, "2025-03-03", "2025-03-06", 3 * 480},
+		{"weekly target without weekdays", []Schedule{{From: "2025-01-01", WeeklyMinutes: 2000}},
+			nil, "2025-03-03", "2025-03-10", 2000},
+		{"monthly target", []Schedule{{From: "2025-01-01", Weekdays: workweek, MonthlyMinutes: 8000}},
+			nil, "2025-03-01", "2025-04-01", 8000},
+		{"schedule starts midweek", []Schedule{{From: "2025-03-05", Weekdays: weekdays(240, "mon", "tue", "wed", "thu", "fri")}},
+			nil, "2025-03-03", "2025-03-10", 2*480 + 3*240},
+		{"latest schedule applies", []Schedule{
+			{From: "2025-01-01", Weekdays: workweek, WeeklyMinutes: 2000},
+			{From: "2025-03-01", Weekdays: workweek, WeeklyMinutes: 1000},
+		}, nil, "2025-03-03", "2025-03-10", 1000},
+	}
+	for _, tt := range tests {
+		s := &Store{
+			Config:  Config{DailyGoalMinutes: 480, WorkDays: []int{1, 2, 3, 4, 5}, Schedules: tt.schedules},
+			DaysOff: tt.daysOff,
+		}
+		if got := periodGoal(s, day(tt.start), day(tt.end)); got != tt.want {
+			t.Errorf("%s: periodGoal = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}